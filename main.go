@@ -19,12 +19,14 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/spf13/pflag"
 	"k8s.io/apiserver/pkg/util/feature"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -32,6 +34,7 @@ import (
 
 	"kusionstack.io/resourceconsist/pkg/adapters"
 	"kusionstack.io/resourceconsist/pkg/frame/webhook"
+	"kusionstack.io/resourceconsist/pkg/utils"
 )
 
 var (
@@ -46,6 +49,17 @@ func main() {
 		probeAddr            string
 		certDir              string
 		dnsName              string
+		certProvider         string
+		certSecretName       string
+		certificateName      string
+		installName          string
+		namespace            string
+		leaderElectionID     string
+		webhookServiceName   string
+		webhookSecretName    string
+		mutatingConfigName   string
+		validatingConfigName string
+		maxEmployeesDrop     float64
 	)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -54,6 +68,17 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&certDir, "cert-dir", webhookTempCertDir(), "The directory that contains the server key and certificate. If not set, webhook server would look up the server key and certificate in {TempDir}/k8s-webhook-server/serving-certs")
 	flag.StringVar(&dnsName, "dns-name", "reosurceconsist-manager.resourceconsist.svc", "The DNS name of the webhook server.")
+	flag.StringVar(&certProvider, "cert-provider", "self-signed", "How webhook certs are provided, one of self-signed, external and cert-manager. Webhook configurations are generated in all modes, only self-signed injects their CA bundle.")
+	flag.StringVar(&certSecretName, "cert-secret-name", "", "The secret holding webhook certs for external and cert-manager cert providers. If not set, external cert provider serves certs in cert-dir as they are.")
+	flag.StringVar(&certificateName, "cert-manager-certificate-name", "", "The cert-manager Certificate whose CA is injected into webhook configurations by cert-manager cert provider. If not set, cert-secret-name is used.")
+	flag.StringVar(&installName, "install-name", "", "The name distinguishing this install from others in the cluster. Finalizers are scoped to it, and names not set below are suffixed by it.")
+	flag.StringVar(&namespace, "namespace", "", "The namespace of webhook service, certs secret and leader election. If not set, POD_NAMESPACE or resourceconsist is used.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "", "The leader election ID, resourceconsist-manager by default.")
+	flag.StringVar(&webhookServiceName, "webhook-service-name", "", "The webhook service, resourceconsist-manager by default.")
+	flag.StringVar(&webhookSecretName, "webhook-certs-secret-name", "", "The secret of self-signed webhook certs, resourceconsist-webhook-certs by default.")
+	flag.StringVar(&mutatingConfigName, "mutating-webhook-configuration-name", "", "The mutating webhook configuration, resourceconsist-manager-mutating by default.")
+	flag.StringVar(&validatingConfigName, "validating-webhook-configuration-name", "", "The validating webhook configuration, resourceconsist-manager-validating by default.")
+	flag.Float64Var(&maxEmployeesDrop, "max-employees-drop-ratio", webhook.DefaultMaxEmployeesDropRatio, "Updates of employer dropping more than this ratio of its employees are rejected by validating webhook. A negative ratio disables the check.")
 
 	klog.InitFlags(nil)
 	defer klog.Flush()
@@ -63,17 +88,26 @@ func main() {
 	pflag.Parse()
 
 	ctrl.SetLogger(klogr.New())
+	// before adapters added, finalizers, annotations and conditions are scoped to install name
+	if err := utils.SetInstallName(installName); err != nil {
+		setupLog.Error(err, "invalid install name")
+		os.Exit(1)
+	}
+	if leaderElectionID == "" {
+		leaderElectionID = utils.InstallScopedName("resourceconsist-manager")
+	}
 
 	config := ctrl.GetConfigOrDie()
 	mgr, err := ctrl.NewManager(config, ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "resourceconsist-manager",
-		CertDir:                certDir,
-		Logger:                 ctrl.Log,
+		Scheme:                  scheme,
+		MetricsBindAddress:      metricsAddr,
+		Port:                    9443,
+		HealthProbeBindAddress:  probeAddr,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        leaderElectionID,
+		LeaderElectionNamespace: namespace,
+		CertDir:                 certDir,
+		Logger:                  ctrl.Log,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -85,18 +119,33 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = adapters.AddAllBuiltinWebhookAdaptersToMgr(mgr); err != nil {
+	if err = adapters.AddAllBuiltinWebhookAdaptersToMgr(mgr, adapters.WithMaxEmployeesDropRatio(maxEmployeesDrop)); err != nil {
 		setupLog.Error(err, "unable to add webhook")
 		os.Exit(1)
 	}
 
 	// +kubebuilder:scaffold:builder
 	setupLog.Info("initialize webhook")
-	if err := webhook.Initialize(context.Background(), config, dnsName, certDir); err != nil {
+	webhookCertProvider, err := newWebhookCertProvider(certProvider, config, dnsName, certDir, certSecretName, certificateName)
+	if err != nil {
+		setupLog.Error(err, "unable to create webhook cert provider")
+		os.Exit(1)
+	}
+	if err := webhook.InitializeWithCertProvider(context.Background(), webhookCertProvider,
+		webhook.WithNamespace(namespace),
+		webhook.WithServiceName(webhookServiceName),
+		webhook.WithCertsSecretName(webhookSecretName),
+		webhook.WithWebhookConfigurationNames(mutatingConfigName, validatingConfigName),
+	); err != nil {
 		setupLog.Error(err, "unable to initialize webhook")
 		os.Exit(1)
 	}
 
+	if err := webhook.AddCertProviderToMgr(mgr, webhookCertProvider, 0); err != nil {
+		setupLog.Error(err, "unable to add webhook cert provider")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -113,6 +162,19 @@ func main() {
 	}
 }
 
+func newWebhookCertProvider(name string, config *rest.Config, dnsName, certDir, secretName, certificateName string) (webhook.CertProvider, error) {
+	switch name {
+	case "self-signed":
+		return webhook.NewSelfSignedCertProvider(config, dnsName, certDir, webhook.CertRotationOptions{})
+	case "external":
+		return webhook.NewExternalCertProvider(config, secretName, certDir)
+	case "cert-manager":
+		return webhook.NewCertManagerCertProvider(config, certificateName, secretName, certDir)
+	default:
+		return nil, fmt.Errorf("unknown cert provider %s", name)
+	}
+}
+
 func webhookTempCertDir() string {
 	return filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
 }
@@ -57,9 +57,28 @@ func AddAllBuiltinControllerAdatersToMgr(mgr manager.Manager) error {
 	return nil
 }
 
-func AddAllBuiltinWebhookAdaptersToMgr(mgr manager.Manager) error {
+// WebhookOption configures validating webhooks of built-in adapters
+type WebhookOption func(*webhookframe.ValidatingOptions)
+
+// WithMaxEmployeesDropRatio sets MaxEmployeesDropRatio of validating webhooks, a negative ratio disables the check
+func WithMaxEmployeesDropRatio(ratio float64) WebhookOption {
+	return func(o *webhookframe.ValidatingOptions) {
+		o.MaxEmployeesDropRatio = ratio
+	}
+}
+
+func newValidatingOptions(opts []WebhookOption) webhookframe.ValidatingOptions {
+	options := webhookframe.ValidatingOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+func AddAllBuiltinWebhookAdaptersToMgr(mgr manager.Manager, opts ...WebhookOption) error {
+	options := newValidatingOptions(opts)
 	for adapterName, adapter := range builtinWebhookAdapters {
-		err := webhookframe.AddToMgr(mgr, adapter)
+		err := addWebhookAdapterToMgr(mgr, adapter, options)
 		if err != nil {
 			return fmt.Errorf("add adapter %s to controller failed, err: %s", adapterName, err.Error())
 		}
@@ -87,17 +106,29 @@ func AddBuiltinControllerAdaptersToMgr(mgr manager.Manager, adapterNames []Adapt
 }
 
 // AddBuiltinWebhookAdaptersToMgr adds webhook adapters of given adapterNames to manager
-func AddBuiltinWebhookAdaptersToMgr(mgr manager.Manager, adapterNames []AdapterName) error {
+func AddBuiltinWebhookAdaptersToMgr(mgr manager.Manager, adapterNames []AdapterName, opts ...WebhookOption) error {
+	options := newValidatingOptions(opts)
 	for _, adapterName := range adapterNames {
 		adapter, exist := builtinWebhookAdapters[adapterName]
 		if !exist {
 			return fmt.Errorf("adapterNames contains %s, which is not built-in adapter", adapterName)
 		}
 
-		err := webhookframe.AddToMgr(mgr, adapter)
+		err := addWebhookAdapterToMgr(mgr, adapter, options)
 		if err != nil {
 			return fmt.Errorf("add adapter %s to controller failed, err: %s", adapterName, err.Error())
 		}
 	}
 	return nil
 }
+
+// addWebhookAdapterToMgr adds validating webhook as well if the adapter implements ValidatingWebhookAdapter
+func addWebhookAdapterToMgr(mgr manager.Manager, adapter webhookframe.WebhookAdapter, options webhookframe.ValidatingOptions) error {
+	if err := webhookframe.AddToMgr(mgr, adapter); err != nil {
+		return err
+	}
+	if validatingAdapter, ok := adapter.(webhookframe.ValidatingWebhookAdapter); ok {
+		return webhookframe.AddValidatingToMgr(mgr, validatingAdapter, options)
+	}
+	return nil
+}
@@ -29,6 +29,7 @@ import (
 )
 
 var _ webhook.WebhookAdapter = &SlbWebhookAdapter{}
+var _ webhook.ValidatingWebhookAdapter = &SlbWebhookAdapter{}
 
 type SlbWebhookAdapter struct {
 }
@@ -65,3 +66,26 @@ func (r *SlbWebhookAdapter) GetEmployersByEmployee(ctx context.Context, employee
 
 	return employers, nil
 }
+
+func (r *SlbWebhookAdapter) NewEmployer() client.Object {
+	return &corev1.Service{}
+}
+
+// GetEmployeeNamesByEmployer returns nothing for services not controlled by kusionstack
+func (r *SlbWebhookAdapter) GetEmployeeNamesByEmployer(ctx context.Context, employer client.Object, c client.Client) ([]string, error) {
+	svc, ok := employer.(*corev1.Service)
+	if !ok || svc.GetLabels()[v1alpha1.ControlledByKusionStackLabelKey] != "true" || len(svc.Spec.Selector) == 0 {
+		return nil, nil
+	}
+	var podList corev1.PodList
+	err := c.List(ctx, &podList, &client.ListOptions{Namespace: svc.Namespace, LabelSelector: labels.SelectorFromSet(svc.Spec.Selector)})
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]string, len(podList.Items))
+	for idx, pod := range podList.Items {
+		selected[idx] = pod.Name
+	}
+	return selected, nil
+}
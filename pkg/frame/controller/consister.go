@@ -20,7 +20,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"reflect"
 	"sort"
 	"strings"
 
@@ -36,23 +35,22 @@ import (
 	"kusionstack.io/resourceconsist/pkg/utils"
 )
 
-func (r *Consist) syncEmployer(ctx context.Context, employer client.Object, expectEmployerStatus, currentEmployerStatus []IEmployer) (bool, bool, CUDEmployerResults, error) {
-	toCudEmployer, err := r.diffEmployer(expectEmployerStatus, currentEmployerStatus)
+func (r *Consist) syncEmployer(ctx context.Context, employer client.Object, toCudEmployer ToCUDEmployer) (bool, bool, CUDEmployerResults, error) {
+	succCreate, failCreate, err := r.provider.CreateEmployer(ctx, employer, toCudEmployer.ToCreate)
 	if err != nil {
-		return false, false, CUDEmployerResults{}, fmt.Errorf("diff employer failed, err: %s", err.Error())
+		return false, false, CUDEmployerResults{}, fmt.Errorf("syncCreate failed, err: %w", err)
 	}
-	succCreate, failCreate, err := r.adapter.CreateEmployer(ctx, employer, toCudEmployer.ToCreate)
+	succCreate, failCreate = r.validateEmployerResults(employer, "CreateEmployer", toCudEmployer.ToCreate, succCreate, failCreate)
+	succUpdate, failUpdate, err := r.provider.UpdateEmployer(ctx, employer, toCudEmployer.ToUpdate)
 	if err != nil {
-		return false, false, CUDEmployerResults{}, fmt.Errorf("syncCreate failed, err: %s", err.Error())
+		return false, false, CUDEmployerResults{}, fmt.Errorf("syncUpdate failed, err: %w", err)
 	}
-	succUpdate, failUpdate, err := r.adapter.UpdateEmployer(ctx, employer, toCudEmployer.ToUpdate)
+	succUpdate, failUpdate = r.validateEmployerResults(employer, "UpdateEmployer", toCudEmployer.ToUpdate, succUpdate, failUpdate)
+	succDelete, failDelete, err := r.provider.DeleteEmployer(ctx, employer, toCudEmployer.ToDelete)
 	if err != nil {
-		return false, false, CUDEmployerResults{}, fmt.Errorf("syncUpdate failed, err: %s", err.Error())
-	}
-	succDelete, failDelete, err := r.adapter.DeleteEmployer(ctx, employer, toCudEmployer.ToDelete)
-	if err != nil {
-		return false, false, CUDEmployerResults{}, fmt.Errorf("syncDelete failed, err: %s", err.Error())
+		return false, false, CUDEmployerResults{}, fmt.Errorf("syncDelete failed, err: %w", err)
 	}
+	succDelete, failDelete = r.validateEmployerResults(employer, "DeleteEmployer", toCudEmployer.ToDelete, succDelete, failDelete)
 
 	isClean := len(toCudEmployer.Unchanged) == 0 && len(toCudEmployer.ToCreate) == 0 && len(toCudEmployer.ToUpdate) == 0 && len(failDelete) == 0
 	cudFailedExist := len(failCreate) > 0 || len(failUpdate) > 0 || len(failDelete) > 0
@@ -64,24 +62,21 @@ func (r *Consist) syncEmployer(ctx context.Context, employer client.Object, expe
 		SuccDeleted: succDelete,
 		FailDeleted: failDelete,
 		Unchanged:   toCudEmployer.Unchanged,
+		Unmanaged:   toCudEmployer.Unmanaged,
+
+		DuplicatedIds: toCudEmployer.DuplicatedIds,
 	}, nil
 }
 
-func (r *Consist) diffEmployer(expectEmployer, currentEmployer []IEmployer) (ToCUDEmployer, error) {
-	expectEmployerMap := make(map[string]IEmployer)
-	currentEmployerMap := make(map[string]IEmployer)
-
-	for _, expect := range expectEmployer {
-		expectEmployerMap[expect.GetEmployerId()] = expect
-	}
-	for _, current := range currentEmployer {
-		currentEmployerMap[current.GetEmployerId()] = current
-	}
+func (r *Consist) diffEmployer(employer client.Object, expectEmployer, currentEmployer []IEmployer) (ToCUDEmployer, error) {
+	expectEmployerMap, expectDuplicated := dedupeById(expectEmployer, employerIdOf, employerSortKey)
+	currentEmployerMap, currentDuplicated := dedupeById(currentEmployer, employerIdOf, employerSortKey)
 
 	toCreate := make([]IEmployer, len(expectEmployer))
 	toUpdate := make([]IEmployer, len(currentEmployer))
 	toDelete := make([]IEmployer, len(currentEmployer))
 	unchanged := make([]IEmployer, len(currentEmployer))
+	var unmanaged []IEmployer
 	toCreateIdx, toUpdateIdx, toDeleteIdx, unchangedIdx := 0, 0, 0, 0
 
 	for expectId, expect := range expectEmployerMap {
@@ -107,41 +102,48 @@ func (r *Consist) diffEmployer(expectEmployer, currentEmployer []IEmployer) (ToC
 	for currentId, current := range currentEmployerMap {
 		_, exist := expectEmployerMap[currentId]
 		if !exist {
+			if !isManagedBy(current, employer) {
+				unmanaged = append(unmanaged, current)
+				continue
+			}
 			toDelete[toDeleteIdx] = current
 			toDeleteIdx++
 		}
 	}
 
+	r.sortEmployers(toCreate[:toCreateIdx])
+	r.sortEmployers(toUpdate[:toUpdateIdx])
+	r.sortEmployers(toDelete[:toDeleteIdx])
+	r.sortEmployers(unchanged[:unchangedIdx])
+	r.sortEmployers(unmanaged)
+
 	r.logger.V(5).Info("employer info",
 		"toCreate", toCreate[:toCreateIdx],
 		"toUpdate", toUpdate[:toUpdateIdx],
 		"toDelete", toDelete[:toDeleteIdx],
 		"unchanged", unchanged[:unchangedIdx],
+		"unmanaged", unmanaged,
 	)
 
 	return ToCUDEmployer{
-		ToCreate:  toCreate[:toCreateIdx],
-		ToUpdate:  toUpdate[:toUpdateIdx],
-		ToDelete:  toDelete[:toDeleteIdx],
-		Unchanged: unchanged[:unchangedIdx],
+		ToCreate:      toCreate[:toCreateIdx],
+		ToUpdate:      toUpdate[:toUpdateIdx],
+		ToDelete:      toDelete[:toDeleteIdx],
+		Unchanged:     unchanged[:unchangedIdx],
+		Unmanaged:     unmanaged,
+		DuplicatedIds: mergeDuplicatedIds(expectDuplicated, currentDuplicated),
 	}, nil
 }
 
-func (r *Consist) diffEmployees(expectEmployees, currentEmployees []IEmployee) (ToCUDEmployees, error) {
-	expectEmployeesMap := make(map[string]IEmployee)
-	currentEmployeesMap := make(map[string]IEmployee)
-
-	for _, expect := range expectEmployees {
-		expectEmployeesMap[expect.GetEmployeeId()] = expect
-	}
-	for _, current := range currentEmployees {
-		currentEmployeesMap[current.GetEmployeeId()] = current
-	}
+func (r *Consist) diffEmployees(employer client.Object, expectEmployees, currentEmployees []IEmployee) (ToCUDEmployees, error) {
+	expectEmployeesMap, expectDuplicated := dedupeById(expectEmployees, employeeIdOf, employeeSortKey)
+	currentEmployeesMap, currentDuplicated := dedupeById(currentEmployees, employeeIdOf, employeeSortKey)
 
 	toCreate := make([]IEmployee, len(expectEmployees))
 	toUpdate := make([]IEmployee, len(currentEmployees))
 	toDelete := make([]IEmployee, len(currentEmployees))
 	unchanged := make([]IEmployee, len(currentEmployees))
+	var unmanaged []IEmployee
 	toCreateIdx, toUpdateIdx, toDeleteIdx, unchangedIdx := 0, 0, 0, 0
 
 	for expectId, expect := range expectEmployeesMap {
@@ -167,45 +169,55 @@ func (r *Consist) diffEmployees(expectEmployees, currentEmployees []IEmployee) (
 	for currentId, current := range currentEmployeesMap {
 		_, exist := expectEmployeesMap[currentId]
 		if !exist {
+			if !isManagedBy(current, employer) {
+				unmanaged = append(unmanaged, current)
+				continue
+			}
 			toDelete[toDeleteIdx] = current
 			toDeleteIdx++
 		}
 	}
 
+	r.sortEmployees(toCreate[:toCreateIdx])
+	r.sortEmployees(toUpdate[:toUpdateIdx])
+	r.sortEmployees(toDelete[:toDeleteIdx])
+	r.sortEmployees(unchanged[:unchangedIdx])
+	r.sortEmployees(unmanaged)
+
 	r.logger.V(5).Info("employee info",
 		"toCreate", toCreate[:toCreateIdx],
 		"toUpdate", toUpdate[:toUpdateIdx],
 		"toDelete", toDelete[:toDeleteIdx],
 		"unchanged", unchanged[:unchangedIdx],
+		"unmanaged", unmanaged,
 	)
 
 	return ToCUDEmployees{
-		ToCreate:  toCreate[:toCreateIdx],
-		ToUpdate:  toUpdate[:toUpdateIdx],
-		ToDelete:  toDelete[:toDeleteIdx],
-		Unchanged: unchanged[:unchangedIdx],
+		ToCreate:      toCreate[:toCreateIdx],
+		ToUpdate:      toUpdate[:toUpdateIdx],
+		ToDelete:      toDelete[:toDeleteIdx],
+		Unchanged:     unchanged[:unchangedIdx],
+		Unmanaged:     unmanaged,
+		DuplicatedIds: mergeDuplicatedIds(expectDuplicated, currentDuplicated),
 	}, nil
 }
 
-func (r *Consist) syncEmployees(ctx context.Context, employer client.Object, expectEmployees, currentEmployees []IEmployee) (bool, bool, CUDEmployeeResults, error) {
-	// get expect/current employees diffEmployees
-	toCudEmployees, err := r.diffEmployees(expectEmployees, currentEmployees)
-	if err != nil {
-		return false, false, CUDEmployeeResults{}, err
-	}
-
-	succCreate, failCreate, err := r.adapter.CreateEmployees(ctx, employer, toCudEmployees.ToCreate)
+func (r *Consist) syncEmployees(ctx context.Context, employer client.Object, toCudEmployees ToCUDEmployees) (bool, bool, CUDEmployeeResults, error) {
+	succCreate, failCreate, err := r.provider.CreateEmployees(ctx, employer, toCudEmployees.ToCreate)
 	if err != nil {
-		return false, false, CUDEmployeeResults{}, fmt.Errorf("syncCreate failed, err: %s", err.Error())
+		return false, false, CUDEmployeeResults{}, fmt.Errorf("syncCreate failed, err: %w", err)
 	}
-	succUpdate, failUpdate, err := r.adapter.UpdateEmployees(ctx, employer, toCudEmployees.ToUpdate)
+	succCreate, failCreate = r.validateEmployeeResults(employer, "CreateEmployees", toCudEmployees.ToCreate, succCreate, failCreate)
+	succUpdate, failUpdate, err := r.provider.UpdateEmployees(ctx, employer, toCudEmployees.ToUpdate)
 	if err != nil {
-		return false, false, CUDEmployeeResults{}, fmt.Errorf("syncUpdate failed, err: %s", err.Error())
+		return false, false, CUDEmployeeResults{}, fmt.Errorf("syncUpdate failed, err: %w", err)
 	}
-	succDelete, failDelete, err := r.adapter.DeleteEmployees(ctx, employer, toCudEmployees.ToDelete)
+	succUpdate, failUpdate = r.validateEmployeeResults(employer, "UpdateEmployees", toCudEmployees.ToUpdate, succUpdate, failUpdate)
+	succDelete, failDelete, err := r.provider.DeleteEmployees(ctx, employer, toCudEmployees.ToDelete)
 	if err != nil {
-		return false, false, CUDEmployeeResults{}, fmt.Errorf("syncDelete failed, err: %s", err.Error())
+		return false, false, CUDEmployeeResults{}, fmt.Errorf("syncDelete failed, err: %w", err)
 	}
+	succDelete, failDelete = r.validateEmployeeResults(employer, "DeleteEmployees", toCudEmployees.ToDelete, succDelete, failDelete)
 
 	toAddLifecycleFlzEmployees, toDeleteLifecycleFlzEmployees := r.getToAddDeleteLifecycleFlzEmployees(
 		succCreate, succDelete, succUpdate, toCudEmployees.Unchanged)
@@ -213,12 +225,12 @@ func (r *Consist) syncEmployees(ctx context.Context, employer client.Object, exp
 	lifecycleOptions, lifecycleOptionsImplemented := r.adapter.(ReconcileLifecycleOptions)
 	needRecordEmployees := lifecycleOptionsImplemented && lifecycleOptions.FollowPodOpsLifeCycle() && lifecycleOptions.NeedRecordLifecycleFinalizerCondition()
 	if needRecordEmployees {
-		if employer.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey] != "" {
+		if employer.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey()] != "" {
 			selectedEmployees, err := lifecycleOptions.GetSelectedEmployeeNames(ctx, employer)
 			if err != nil {
-				return false, false, CUDEmployeeResults{}, fmt.Errorf("GetSelectedEmployeeNames failed, err: %s", err.Error())
+				return false, false, CUDEmployeeResults{}, fmt.Errorf("GetSelectedEmployeeNames failed, err: %w", err)
 			}
-			recordedEmployees := strings.Split(employer.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey], ",")
+			recordedEmployees := splitRecordedNames(employer.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey()])
 			selectedSet := sets.NewString(selectedEmployees...)
 			for _, recordedEmployee := range recordedEmployees {
 				if !selectedSet.Has(recordedEmployee) {
@@ -232,34 +244,19 @@ func (r *Consist) syncEmployees(ctx context.Context, employer client.Object, exp
 	lifecycleFlz := utils.GenerateLifecycleFinalizer(employer.GetName())
 	err = r.ensureLifecycleFinalizer(ctx, ns, lifecycleFlz, toAddLifecycleFlzEmployees, toDeleteLifecycleFlzEmployees)
 	if err != nil {
-		return false, false, CUDEmployeeResults{}, fmt.Errorf("ensureLifecycleFinalizer failed, err: %s", err.Error())
+		return false, false, CUDEmployeeResults{}, fmt.Errorf("ensureLifecycleFinalizer failed, err: %w", err)
 	}
 
 	if needRecordEmployees {
-		needUpdate := false
-		if employer.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey] == "" {
-			if len(toAddLifecycleFlzEmployees) != 0 {
-				needUpdate = true
-			}
-		} else {
-			recordedEmployees := strings.Split(employer.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey], ",")
-			if len(recordedEmployees) != len(toAddLifecycleFlzEmployees) {
-				needUpdate = true
-			} else {
-				sort.Strings(recordedEmployees)
-				sort.Strings(toAddLifecycleFlzEmployees)
-				if !reflect.DeepEqual(recordedEmployees, toAddLifecycleFlzEmployees) {
-					needUpdate = true
-				}
-			}
-		}
+		needUpdate := lifecycleFlzRecordNeedUpdate(employer.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey()], toAddLifecycleFlzEmployees)
 		if needUpdate {
+			sort.Strings(toAddLifecycleFlzEmployees)
 			patch := client.MergeFrom(employer.DeepCopyObject().(client.Object))
 			annos := employer.GetAnnotations()
 			if annos == nil {
 				annos = make(map[string]string)
 			}
-			annos[lifecycleFinalizerRecordedAnnoKey] = strings.Join(toAddLifecycleFlzEmployees, ",")
+			annos[lifecycleFinalizerRecordedAnnoKey()] = strings.Join(toAddLifecycleFlzEmployees, ",")
 			employer.SetAnnotations(annos)
 			if _, ok := r.adapter.(MultiClusterOptions); ok {
 				err = r.Client.Patch(clusterinfo.WithCluster(ctx, clusterinfo.Fed), employer, patch)
@@ -267,7 +264,7 @@ func (r *Consist) syncEmployees(ctx context.Context, employer client.Object, exp
 				err = r.Client.Patch(ctx, employer, patch)
 			}
 			if err != nil {
-				return false, false, CUDEmployeeResults{}, fmt.Errorf("patch lifecycleFinalizerRecordedAnno failed, err: %s", err.Error())
+				return false, false, CUDEmployeeResults{}, fmt.Errorf("patch lifecycleFinalizerRecordedAnno failed, err: %w", err)
 			}
 		}
 	}
@@ -282,6 +279,9 @@ func (r *Consist) syncEmployees(ctx context.Context, employer client.Object, exp
 		SuccDeleted: succDelete,
 		FailDeleted: failDelete,
 		Unchanged:   toCudEmployees.Unchanged,
+		Unmanaged:   toCudEmployees.Unmanaged,
+
+		DuplicatedIds: toCudEmployees.DuplicatedIds,
 	}, nil
 }
 
@@ -299,7 +299,7 @@ func (r *Consist) ensureExpectedFinalizer(ctx context.Context, employer client.O
 
 	selectedEmployeeNames, err := lifecycleOptions.GetSelectedEmployeeNames(ctx, employer)
 	if err != nil {
-		return false, fmt.Errorf("get selected employees' names failed, err: %s", err.Error())
+		return false, fmt.Errorf("get selected employees' names failed, err: %w", err)
 	}
 
 	recordOptions, recordOptionsImplemented := r.adapter.(ExpectedFinalizerRecordOptions)
@@ -346,7 +346,7 @@ func (r *Consist) ensureExpectedFinalizerNotNeedRecord(ctx context.Context, empl
 func (r *Consist) ensureExpectedFinalizerNeedRecord(ctx context.Context, employer client.Object, selectedEmployeeNames []string) (bool, error) {
 	var err error
 	var toAdd, toDelete []PodExpectedFinalizerOps
-	addedExpectedFinalizerPodNames := strings.Split(employer.GetAnnotations()[expectedFinalizerAddedAnnoKey], ",")
+	addedExpectedFinalizerPodNames := splitRecordedNames(employer.GetAnnotations()[expectedFinalizerAddedAnnoKey()])
 
 	if !employer.GetDeletionTimestamp().IsZero() {
 		toDeleteNames := sets.NewString(addedExpectedFinalizerPodNames...).Insert(selectedEmployeeNames...).List()
@@ -368,10 +368,10 @@ func (r *Consist) ensureExpectedFinalizerNeedRecord(ctx context.Context, employe
 		if annos == nil {
 			annos = make(map[string]string)
 		}
-		if annos[expectedFinalizerAddedAnnoKey] == strings.Join(notDeletedPodNames, ",") {
+		if annos[expectedFinalizerAddedAnnoKey()] == strings.Join(notDeletedPodNames, ",") {
 			return len(notDeletedPodNames) == 0, nil
 		}
-		annos[expectedFinalizerAddedAnnoKey] = strings.Join(notDeletedPodNames, ",")
+		annos[expectedFinalizerAddedAnnoKey()] = strings.Join(notDeletedPodNames, ",")
 		employer.SetAnnotations(annos)
 		if _, ok := r.adapter.(MultiClusterOptions); ok {
 			err = r.Client.Patch(clusterinfo.WithCluster(ctx, clusterinfo.Fed), employer, patch)
@@ -402,34 +402,17 @@ func (r *Consist) ensureExpectedFinalizerNeedRecord(ctx context.Context, employe
 	}
 
 	errPatchEmployees := r.patchPodExpectedFinalizer(ctx, employer, toAdd, toDelete)
-	var succDeletedNames []string
-	for _, deleteExpectFinalizerOps := range toDelete {
-		if deleteExpectFinalizerOps.Succeed {
-			succDeletedNames = append(succDeletedNames, deleteExpectFinalizerOps.Name)
-		}
-	}
-	succDeletedNamesSet := sets.NewString(succDeletedNames...)
-	var addedNames []string
-	for _, added := range addedExpectedFinalizerPodNames {
-		if !succDeletedNamesSet.Has(added) {
-			addedNames = append(addedNames, added)
-		}
-	}
-	for _, addExpectedFinalizerOps := range toAdd {
-		if addExpectedFinalizerOps.Succeed {
-			addedNames = append(addedNames, addExpectedFinalizerOps.Name)
-		}
-	}
+	addedNames := expectedFinalizerAddedNames(addedExpectedFinalizerPodNames, toAdd, toDelete)
 
 	patch := client.MergeFrom(employer.DeepCopyObject().(client.Object))
 	annos := employer.GetAnnotations()
 	if annos == nil {
 		annos = make(map[string]string)
 	}
-	if annos[expectedFinalizerAddedAnnoKey] == strings.Join(addedNames, ",") {
+	if annos[expectedFinalizerAddedAnnoKey()] == strings.Join(addedNames, ",") {
 		return len(addedNames) == 0, nil
 	}
-	annos[expectedFinalizerAddedAnnoKey] = strings.Join(addedNames, ",")
+	annos[expectedFinalizerAddedAnnoKey()] = strings.Join(addedNames, ",")
 	employer.SetAnnotations(annos)
 
 	if _, ok := r.adapter.(MultiClusterOptions); ok {
@@ -500,7 +483,6 @@ func (r *Consist) patchAddPodExpectedFinalizer(ctx context.Context, employer cli
 		if !pod.GetDeletionTimestamp().IsZero() {
 			return nil
 		}
-
 		patch := client.MergeFrom(pod.DeepCopy())
 
 		var availableExpectedFlzs v1alpha1.PodAvailableConditions
@@ -616,19 +598,19 @@ func (r *Consist) patchDeletePodExpectedFinalizer(ctx context.Context, employer
 			return err
 		}
 
-		patch := client.MergeFrom(pod.DeepCopy())
-
 		var availableExpectedFlzs v1alpha1.PodAvailableConditions
-		if pod.Annotations == nil || pod.Annotations[v1alpha1.PodAvailableConditionsAnnotation] == "" {
+		anno := pod.Annotations[v1alpha1.PodAvailableConditionsAnnotation]
+		if anno == "" {
 			podExpectedFinalizerOps.Succeed = true
 			return nil
 		}
 
-		errUnmarshal := json.Unmarshal([]byte(pod.Annotations[v1alpha1.PodAvailableConditionsAnnotation]), &availableExpectedFlzs)
+		errUnmarshal := json.Unmarshal([]byte(anno), &availableExpectedFlzs)
 		if errUnmarshal != nil {
 			return errUnmarshal
 		}
 		if _, exist := availableExpectedFlzs.ExpectedFinalizers[expectedFlzKey]; exist {
+			patch := client.MergeFrom(pod.DeepCopy())
 			delete(availableExpectedFlzs.ExpectedFinalizers, expectedFlzKey)
 			annoAvailableExpectedFlzs, errMarshal := json.Marshal(availableExpectedFlzs)
 			if errMarshal != nil {
@@ -692,7 +674,7 @@ func (r *Consist) cleanEmployerCleanFinalizer(ctx context.Context, employer clie
 			alreadyDeleted = false
 			continue
 		}
-		if flz == cleanFinalizer {
+		if flz == utils.GenerateCleanFinalizer() {
 			alreadyDeleted = false
 			continue
 		}
@@ -902,7 +884,7 @@ func (r *Consist) ensureEmployerCleanFlz(ctx context.Context, employer client.Ob
 	}
 	var finalizers []string
 	for _, flz := range employer.GetFinalizers() {
-		if flz == cleanFinalizer {
+		if flz == utils.GenerateCleanFinalizer() {
 			return false, nil
 		}
 		if flz == generateOldCleanFlz(employer) {
@@ -910,7 +892,7 @@ func (r *Consist) ensureEmployerCleanFlz(ctx context.Context, employer client.Ob
 		}
 		finalizers = append(finalizers, flz)
 	}
-	employer.SetFinalizers(append(finalizers, cleanFinalizer))
+	employer.SetFinalizers(append(finalizers, utils.GenerateCleanFinalizer()))
 	if _, ok := r.adapter.(MultiClusterOptions); ok {
 		return true, r.Client.Update(clusterinfo.WithCluster(ctx, clusterinfo.Fed), employer)
 	}
@@ -918,5 +900,94 @@ func (r *Consist) ensureEmployerCleanFlz(ctx context.Context, employer client.Ob
 }
 
 func generateOldCleanFlz(employer client.Object) string {
-	return cleanFinalizerPrefix + employer.GetName()
+	return utils.GenerateOldCleanFinalizer(employer.GetName())
+}
+
+func (r *Consist) patchEmployer(ctx context.Context, employer client.Object, patch client.Patch) error {
+	if _, ok := r.adapter.(MultiClusterOptions); ok {
+		return r.Client.Patch(clusterinfo.WithCluster(ctx, clusterinfo.Fed), employer, patch)
+	}
+	return r.Client.Patch(ctx, employer, patch)
+}
+
+// newEmployer returns an empty employer object of adapter
+func (r *Consist) newEmployer() client.Object {
+	if watchOptions, ok := r.adapter.(ReconcileWatchOptions); ok {
+		return watchOptions.NewEmployer()
+	}
+	return &corev1.Service{}
+}
+
+// sortEmployers sorts employers by id, or by DiffOrderOptions if implemented
+func (r *Consist) sortEmployers(employers []IEmployer) {
+	sort.Slice(employers, func(i, j int) bool {
+		return employers[i].GetEmployerId() < employers[j].GetEmployerId()
+	})
+	if orderOptions, ok := r.adapter.(DiffOrderOptions); ok {
+		sort.SliceStable(employers, func(i, j int) bool {
+			return orderOptions.LessEmployer(employers[i], employers[j])
+		})
+	}
+}
+
+// sortEmployees sorts employees by id, or by DiffOrderOptions if implemented
+func (r *Consist) sortEmployees(employees []IEmployee) {
+	sort.Slice(employees, func(i, j int) bool {
+		return employees[i].GetEmployeeId() < employees[j].GetEmployeeId()
+	})
+	if orderOptions, ok := r.adapter.(DiffOrderOptions); ok {
+		sort.SliceStable(employees, func(i, j int) bool {
+			return orderOptions.LessEmployee(employees[i], employees[j])
+		})
+	}
+}
+
+// splitRecordedNames splits names recorded in annotation, an empty annotation records no names
+func splitRecordedNames(recorded string) []string {
+	if recorded == "" {
+		return nil
+	}
+	return strings.Split(recorded, ",")
+}
+
+// lifecycleFlzRecordNeedUpdate returns whether names recorded differ from toAdd regardless of order, it counts names
+// instead of sorting both since employers may have tens of thousands of employees
+func lifecycleFlzRecordNeedUpdate(recorded string, toAdd []string) bool {
+	recordedNames := splitRecordedNames(recorded)
+	if len(recordedNames) != len(toAdd) {
+		return true
+	}
+	counts := make(map[string]int, len(recordedNames))
+	for _, name := range recordedNames {
+		counts[name]++
+	}
+	for _, name := range toAdd {
+		if counts[name] == 0 {
+			return true
+		}
+		counts[name]--
+	}
+	return false
+}
+
+// expectedFinalizerAddedNames returns names of pods with expected finalizer added after toAdd and toDelete patched
+func expectedFinalizerAddedNames(added []string, toAdd, toDelete []PodExpectedFinalizerOps) []string {
+	succDeletedNamesSet := sets.NewString()
+	for _, deleteExpectFinalizerOps := range toDelete {
+		if deleteExpectFinalizerOps.Succeed {
+			succDeletedNamesSet.Insert(deleteExpectFinalizerOps.Name)
+		}
+	}
+	var addedNames []string
+	for _, name := range added {
+		if !succDeletedNamesSet.Has(name) {
+			addedNames = append(addedNames, name)
+		}
+	}
+	for _, addExpectedFinalizerOps := range toAdd {
+		if addExpectedFinalizerOps.Succeed {
+			addedNames = append(addedNames, addExpectedFinalizerOps.Name)
+		}
+	}
+	return addedNames
 }
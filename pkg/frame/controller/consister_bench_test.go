@@ -0,0 +1,180 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/kube-api/apps/v1alpha1"
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+var benchmarkScales = []int{1000, 10000, 50000}
+
+func benchmarkEmployer() *corev1.Service {
+	return &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+}
+
+// benchmarkEmployees returns n employees, a tenth of them differ between expected and current
+func benchmarkEmployees(n int) (expected, current []IEmployee) {
+	expected = make([]IEmployee, n)
+	current = make([]IEmployee, n)
+	for i := 0; i < n; i++ {
+		name := "pod-" + strconv.Itoa(i)
+		expected[i] = &DemoPodStatus{EmployeeId: name, EmployeeName: name,
+			EmployeeStatuses: PodEmployeeStatuses{Ip: name, LifecycleReady: true}}
+		current[i] = &DemoPodStatus{EmployeeId: name, EmployeeName: name,
+			EmployeeStatuses: PodEmployeeStatuses{Ip: name, LifecycleReady: i%10 != 0}}
+	}
+	// shift a twentieth of current, to be created and deleted
+	for i := 0; i < n/20; i++ {
+		name := "stale-pod-" + strconv.Itoa(i)
+		current[i] = &DemoPodStatus{EmployeeId: name, EmployeeName: name,
+			EmployeeStatuses: PodEmployeeStatuses{Ip: name, LifecycleReady: true}}
+	}
+	return expected, current
+}
+
+func benchmarkNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = "pod-" + strconv.Itoa(i)
+	}
+	return names
+}
+
+func BenchmarkDiffEmployees(b *testing.B) {
+	for _, n := range benchmarkScales {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			r := newTestConsist()
+			employer := benchmarkEmployer()
+			expected, current := benchmarkEmployees(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.diffEmployees(employer, expected, current); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetToAddDeleteLifecycleFlzEmployees(b *testing.B) {
+	for _, n := range benchmarkScales {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			r := newTestConsist()
+			expected, current := benchmarkEmployees(n)
+			toCud, err := r.diffEmployees(benchmarkEmployer(), expected, current)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.getToAddDeleteLifecycleFlzEmployees(toCud.ToCreate, toCud.ToDelete, toCud.ToUpdate, toCud.Unchanged)
+			}
+		})
+	}
+}
+
+func BenchmarkLifecycleFlzRecordNeedUpdate(b *testing.B) {
+	for _, n := range benchmarkScales {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			names := benchmarkNames(n)
+			recorded := strings.Join(names, ",")
+			// names to add in another order, nothing changed
+			toAdd := append(append([]string(nil), names[n/2:]...), names[:n/2]...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if lifecycleFlzRecordNeedUpdate(recorded, toAdd) {
+					b.Fatal("no update expected")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExpectedFinalizerAddedNames(b *testing.B) {
+	for _, n := range benchmarkScales {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			names := benchmarkNames(n)
+			toAdd := make([]PodExpectedFinalizerOps, n/10)
+			for i := range toAdd {
+				toAdd[i] = PodExpectedFinalizerOps{Name: "new-" + names[i], Succeed: true}
+			}
+			toDelete := make([]PodExpectedFinalizerOps, n/10)
+			for i := range toDelete {
+				toDelete[i] = PodExpectedFinalizerOps{Name: names[i], Succeed: true}
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				expectedFinalizerAddedNames(names, toAdd, toDelete)
+			}
+		})
+	}
+}
+
+// BenchmarkEnsureExpectedFinalizer covers ensureExpectedFinalizer against a fake client in steady state, all selected
+// pods already carrying the expected finalizer of the employer among those of other employers
+func BenchmarkEnsureExpectedFinalizer(b *testing.B) {
+	employer := benchmarkEmployer()
+	conditions := v1alpha1.PodAvailableConditions{ExpectedFinalizers: map[string]string{
+		utils.GenerateLifecycleFinalizerKey(employer): utils.GenerateLifecycleFinalizer(employer.GetName()),
+	}}
+	for i := 0; i < 5; i++ {
+		other := benchmarkEmployer()
+		other.Name = fmt.Sprintf("other-svc-%d", i)
+		conditions.ExpectedFinalizers[utils.GenerateLifecycleFinalizerKey(other)] = utils.GenerateLifecycleFinalizer(other.Name)
+	}
+	anno, err := json.Marshal(conditions)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, n := range []int{100, 1000, 10000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			names := benchmarkNames(n)
+			objs := []client.Object{employer.DeepCopy()}
+			for _, name := range names {
+				objs = append(objs, &corev1.Pod{ObjectMeta: v1.ObjectMeta{
+					Namespace:   employer.Namespace,
+					Name:        name,
+					Annotations: map[string]string{v1alpha1.PodAvailableConditionsAnnotation: string(anno)},
+				}})
+			}
+			adapter := newMemoryAdapter(nil)
+			adapter.followLifecycle = true
+			adapter.selected = names
+			r := newFakeConsist(adapter, objs)
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.ensureExpectedFinalizer(ctx, employer); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
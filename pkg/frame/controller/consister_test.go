@@ -0,0 +1,131 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type ownedDemoPodStatus struct {
+	DemoPodStatus
+	Owner string
+}
+
+func (o *ownedDemoPodStatus) IsManagedBy(employer client.Object) bool {
+	return o.Owner == employer.GetName()
+}
+
+func newTestConsist() *Consist {
+	return &Consist{
+		adapter: &DemoControllerAdapter{},
+		logger:  logr.Discard(),
+	}
+}
+
+func TestDiffEmployeesSkipUnmanaged(t *testing.T) {
+	employer := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+	current := []IEmployee{
+		&ownedDemoPodStatus{DemoPodStatus: DemoPodStatus{EmployeeId: "owned", EmployeeName: "owned"}, Owner: "svc"},
+		&ownedDemoPodStatus{DemoPodStatus: DemoPodStatus{EmployeeId: "foreign", EmployeeName: "foreign"}, Owner: "other"},
+		&DemoPodStatus{EmployeeId: "legacy", EmployeeName: "legacy"},
+	}
+
+	toCud, err := newTestConsist().diffEmployees(employer, nil, current)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"owned", "legacy"}, employeeIds(toCud.ToDelete))
+	assert.Equal(t, []string{"foreign"}, employeeIds(toCud.Unmanaged))
+}
+
+func TestDiffEmployeesDuplicatedIds(t *testing.T) {
+	employer := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+	expected := []IEmployee{
+		&DemoPodStatus{EmployeeId: "10.0.0.1", EmployeeName: "pod-b"},
+		&DemoPodStatus{EmployeeId: "10.0.0.1", EmployeeName: "pod-a"},
+		&DemoPodStatus{EmployeeId: "10.0.0.2", EmployeeName: "pod-c"},
+	}
+	reversed := []IEmployee{expected[2], expected[1], expected[0]}
+
+	for _, expect := range [][]IEmployee{expected, reversed} {
+		toCud, err := newTestConsist().diffEmployees(employer, expect, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.1"}, toCud.DuplicatedIds)
+		assert.Len(t, toCud.ToCreate, 2)
+		for _, toCreate := range toCud.ToCreate {
+			if toCreate.GetEmployeeId() == "10.0.0.1" {
+				assert.Equal(t, "pod-a", toCreate.GetEmployeeName())
+			}
+		}
+	}
+}
+
+func randomDemoPods(rnd *rand.Rand, n int) []IEmployee {
+	employees := make([]IEmployee, n)
+	for i := range employees {
+		id := strconv.Itoa(rnd.Intn(2 * n))
+		employees[i] = &DemoPodStatus{EmployeeId: id, EmployeeName: "pod-" + id,
+			EmployeeStatuses: PodEmployeeStatuses{Ip: id, LifecycleReady: rnd.Intn(2) == 0}}
+	}
+	return employees
+}
+
+func shuffled(rnd *rand.Rand, employees []IEmployee) []IEmployee {
+	result := append([]IEmployee(nil), employees...)
+	rnd.Shuffle(len(result), func(i, j int) { result[i], result[j] = result[j], result[i] })
+	return result
+}
+
+// identical expected/current give identical plans, regardless of their order
+func TestDiffEmployeesDeterministic(t *testing.T) {
+	employer := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "svc", Namespace: "default"}}
+	property := func(seed int64) bool {
+		rnd := rand.New(rand.NewSource(seed))
+		expected := randomDemoPods(rnd, rnd.Intn(50))
+		current := randomDemoPods(rnd, rnd.Intn(50))
+
+		plan, err := newTestConsist().diffEmployees(employer, expected, current)
+		if err != nil {
+			return false
+		}
+		for i := 0; i < 5; i++ {
+			another, err := newTestConsist().diffEmployees(employer, shuffled(rnd, expected), shuffled(rnd, current))
+			if err != nil || !reflect.DeepEqual(plan, another) {
+				return false
+			}
+		}
+		return true
+	}
+	assert.NoError(t, quick.Check(property, nil))
+}
+
+func TestLifecycleFlzRecordNeedUpdate(t *testing.T) {
+	assert.False(t, lifecycleFlzRecordNeedUpdate("", nil))
+	assert.True(t, lifecycleFlzRecordNeedUpdate("", []string{"pod-a"}))
+	assert.True(t, lifecycleFlzRecordNeedUpdate("pod-a", nil))
+	assert.False(t, lifecycleFlzRecordNeedUpdate("pod-a,pod-b", []string{"pod-b", "pod-a"}))
+	assert.True(t, lifecycleFlzRecordNeedUpdate("pod-a,pod-b", []string{"pod-a", "pod-c"}))
+	assert.True(t, lifecycleFlzRecordNeedUpdate("pod-a,pod-b", []string{"pod-a", "pod-a"}))
+}
@@ -16,13 +16,57 @@ limitations under the License.
 
 package controller
 
+import "time"
+
+const (
+	defaultMaxConcurrentReconciles = 5
+
+	// annotation keys and condition types below are scoped to install name, see install_scope.go
+	defaultExpectedFinalizerAddedAnnoKey     = "resource-consist.kusionstack.io/employees-expected-finalizer-added"
+	defaultLifecycleFinalizerRecordedAnnoKey = "resource-consist.kusionstack.io/employees-lifecycle-finalizer-recorded"
+)
+
+const defaultDeletionPolicyAnnoKey = "resource-consist.kusionstack.io/deletion-policy"
+
+const (
+	// DeletionPolicyDelete deletes resources related to employer and employees on backend provider, the default policy
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps resources on backend provider and records what was left behind in an event
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyOrphan keeps resources on backend provider without querying them
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+const defaultImportConfirmedAnnoKey = "resource-consist.kusionstack.io/import-confirmed"
+
+const (
+	defaultImportStateAnnoKey  = "resource-consist.kusionstack.io/import-state"
+	defaultImportReportAnnoKey = "resource-consist.kusionstack.io/import-report"
+	// importReportSampleSize is the max number of ids kept for each list of import report, keeping the anno far below
+	// the 256KiB limit of annotations, and for each list of events, see idSample
+	importReportSampleSize = 20
+
+	// importStatePending means resources on backend provider not adopted yet
+	importStatePending = "Pending"
+	// importStateAdopted means resources on backend provider adopted, waiting for confirmation
+	importStateAdopted = "Adopted"
+)
+
+const (
+	// DuplicateIdPolicyPick picks one of items sharing an id deterministically and reports the id, the default policy
+	DuplicateIdPolicyPick DuplicateIdPolicy = "Pick"
+	// DuplicateIdPolicyFail fails the reconcile and reports the id, employer is requeued after duplicateIdRetryAfter
+	DuplicateIdPolicyFail DuplicateIdPolicy = "Fail"
+
+	// duplicateIdRetryAfter is fixed instead of exponential backoff, since ids are usually fixed out of band on backend
+	// provider without employer changed
+	duplicateIdRetryAfter = time.Minute
+)
+
 const (
-	defaultMaxConcurrentReconciles    = 5
-	expectedFinalizerAddedAnnoKey     = "resource-consist.kusionstack.io/employees-expected-finalizer-added"
-	lifecycleFinalizerRecordedAnnoKey = "resource-consist.kusionstack.io/employees-lifecycle-finalizer-recorded"
-	// cleanFinalizerPrefix would be deprecated in the future
-	cleanFinalizerPrefix = "resource-consist.kusionstack.io/clean-"
-	cleanFinalizer       = "resource-consist.kusionstack.io/clean-finalizer"
+	defaultDriftConditionType         = "ResourceConsistDrifted"
+	defaultDuplicateIdsConditionType  = "ResourceConsistDuplicateIds"
+	defaultTerminalErrorConditionType = "ResourceConsistTerminalError"
 )
 
 // Event reason list
@@ -40,4 +84,20 @@ const (
 	CleanEmployerCleanFinalizerSucceed  = "CleanEmployerCleanFinalizerSucceed"
 	RecordStatusesFailed                = "RecordStatusesFailed"
 	RecordErrorConditionsFailed         = "RecordErrorConditionsFailed"
+	RetainBackendResourcesFailed        = "RetainBackendResourcesFailed"
+	BackendResourcesRetained            = "BackendResourcesRetained"
+	BackendResourcesOrphaned            = "BackendResourcesOrphaned"
+	AdoptBackendResourcesFailed         = "AdoptBackendResourcesFailed"
+	BackendResourcesAdopted             = "BackendResourcesAdopted"
+	ImportConfirmed                     = "ImportConfirmed"
+	ImportSkipped                       = "ImportSkipped"
+	ObserveDriftFailed                  = "ObserveDriftFailed"
+	UnmanagedResourcesSkipped           = "UnmanagedResourcesSkipped"
+	ProviderCircuitOpen                 = "ProviderCircuitOpen"
+	AdapterCallTimeout                  = "AdapterCallTimeout"
+	ReconcileHookFailed                 = "ReconcileHookFailed"
+	ReconcileAbortedByHook              = "ReconcileAbortedByHook"
+	InvalidAdapterResults               = "InvalidAdapterResults"
+	DuplicateIdsFound                   = "DuplicateIdsFound"
+	StaleFinalizersRemoved              = "StaleFinalizersRemoved"
 )
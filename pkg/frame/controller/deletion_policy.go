@@ -0,0 +1,113 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+// getDeletionPolicy returns employer's deletion policy, DeletionPolicyDelete if not set or invalid.
+// Employer whose import not confirmed yet is regarded as DeletionPolicyRetain, since adopted resources are not ours yet.
+func getDeletionPolicy(employer client.Object) DeletionPolicy {
+	if isImportUnconfirmed(employer) {
+		if DeletionPolicy(employer.GetAnnotations()[DeletionPolicyAnnoKey()]) == DeletionPolicyOrphan {
+			return DeletionPolicyOrphan
+		}
+		return DeletionPolicyRetain
+	}
+	switch DeletionPolicy(employer.GetAnnotations()[DeletionPolicyAnnoKey()]) {
+	case DeletionPolicyRetain:
+		return DeletionPolicyRetain
+	case DeletionPolicyOrphan:
+		return DeletionPolicyOrphan
+	default:
+		return DeletionPolicyDelete
+	}
+}
+
+// retainBackendResources is called instead of syncEmployer/syncEmployees for deleting employer whose deletion policy
+// is not DeletionPolicyDelete. Resources on backend provider are left intact and marked as retained if
+// ManagedEmployerLister implemented, while lifecycle finalizers on employees are cleaned so that employees won't be
+// blocked by a deleted employer.
+func (r *Consist) retainBackendResources(ctx context.Context, employer client.Object, policy DeletionPolicy) (err error) {
+	toDeleteLifecycleFlzEmployees := sets.NewString()
+	// reported only once all done, not on every retry
+	reason, message := BackendResourcesOrphaned, "backend resources orphaned"
+	defer func() {
+		if err == nil {
+			r.recorder.Event(employer, corev1.EventTypeNormal, reason, message)
+		}
+	}()
+
+	if lister, ok := r.adapter.(ManagedEmployerLister); ok {
+		if err := lister.MarkRetained(ctx, employer, policy); err != nil {
+			return fmt.Errorf("mark retained failed, err: %w", err)
+		}
+	}
+
+	if policy == DeletionPolicyRetain {
+		currentEmployer, err := r.provider.GetCurrentEmployer(ctx, employer)
+		if err != nil {
+			return fmt.Errorf("get current employer failed, err: %w", err)
+		}
+		currentEmployees, err := r.provider.GetCurrentEmployee(ctx, employer)
+		if err != nil {
+			return fmt.Errorf("get current employees failed, err: %w", err)
+		}
+
+		for _, current := range currentEmployees {
+			if current.GetEmployeeName() != "" {
+				toDeleteLifecycleFlzEmployees.Insert(current.GetEmployeeName())
+			}
+		}
+		reason = BackendResourcesRetained
+		message = fmt.Sprintf("backend resources retained, employer: %s, employees: %s",
+			idSample(employerIds(currentEmployer)), idSample(employeeIds(currentEmployees)))
+	}
+
+	watchOptions, watchOptionsImplemented := r.adapter.(ReconcileWatchOptions)
+	lifecycleOptions, lifecycleOptionsImplemented := r.adapter.(ReconcileLifecycleOptions)
+	if (lifecycleOptionsImplemented && !lifecycleOptions.FollowPodOpsLifeCycle()) || (watchOptionsImplemented && !isPod(watchOptions.NewEmployee())) {
+		return nil
+	}
+
+	if lifecycleOptionsImplemented {
+		selectedEmployees, err := lifecycleOptions.GetSelectedEmployeeNames(ctx, employer)
+		if err != nil {
+			return fmt.Errorf("GetSelectedEmployeeNames failed, err: %w", err)
+		}
+		toDeleteLifecycleFlzEmployees.Insert(selectedEmployees...)
+	}
+	if recorded := employer.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey()]; recorded != "" {
+		toDeleteLifecycleFlzEmployees.Insert(strings.Split(recorded, ",")...)
+	}
+
+	lifecycleFlz := utils.GenerateLifecycleFinalizer(employer.GetName())
+	err = r.ensureLifecycleFinalizer(ctx, employer.GetNamespace(), lifecycleFlz, nil, toDeleteLifecycleFlzEmployees.List())
+	if err != nil {
+		return fmt.Errorf("ensureLifecycleFinalizer failed, err: %w", err)
+	}
+	return nil
+}
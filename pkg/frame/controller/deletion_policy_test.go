@@ -0,0 +1,107 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+func TestDeletionPolicy(t *testing.T) {
+	cases := []struct {
+		policy           DeletionPolicy
+		expectEmployers  []string
+		expectEmployees  []string
+		expectDeleteCall bool
+	}{
+		{policy: DeletionPolicyRetain, expectEmployers: []string{"svc"}, expectEmployees: []string{"pod-a"}},
+		{policy: DeletionPolicyOrphan, expectEmployers: []string{"svc"}, expectEmployees: []string{"pod-a"}},
+		{policy: DeletionPolicyDelete, expectDeleteCall: true},
+	}
+	for _, tc := range cases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			ctx := context.Background()
+			adapter := newMemoryAdapter([]string{"svc"}, "pod-a")
+			svc := newTestService("svc", map[string]string{DeletionPolicyAnnoKey(): string(tc.policy)},
+				utils.GenerateCleanFinalizer())
+			r := newFakeConsist(adapter, []client.Object{svc})
+			require.NoError(t, r.Delete(ctx, svc))
+
+			_, err := reconcileEmployer(t, r, svc)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectEmployers, adapter.employerIds())
+			assert.Equal(t, tc.expectEmployees, adapter.employeeIds())
+			calls := adapter.getCalls()
+			assert.Equal(t, tc.expectDeleteCall, contains(calls, "DeleteEmployer"))
+			assert.Equal(t, tc.expectDeleteCall, contains(calls, "DeleteEmployees"))
+			assert.False(t, contains(calls, "CreateEmployer") || contains(calls, "CreateEmployees"))
+
+			// clean finalizer removed, so the service is gone
+			err = r.Get(ctx, client.ObjectKeyFromObject(svc), &corev1.Service{})
+			assert.True(t, errors.IsNotFound(err), "expect service deleted, got %v", err)
+		})
+	}
+}
+
+func TestRetainedEventBounded(t *testing.T) {
+	ctx := context.Background()
+	var names []string
+	for i := 0; i < importReportSampleSize+10; i++ {
+		names = append(names, fmt.Sprintf("pod-%d", i))
+	}
+	adapter := newMemoryAdapter([]string{"svc"}, names...)
+	svc := newTestService("svc", map[string]string{DeletionPolicyAnnoKey(): string(DeletionPolicyRetain)},
+		utils.GenerateCleanFinalizer())
+	r := newFakeConsist(adapter, []client.Object{svc})
+	recorder := record.NewFakeRecorder(100)
+	r.recorder = recorder
+	require.NoError(t, r.Delete(ctx, svc))
+
+	_, err := reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	var retained []string
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, BackendResourcesRetained) {
+			retained = append(retained, event)
+		}
+	}
+	require.Len(t, retained, 1)
+	assert.Contains(t, retained[0], "employer: 1 [svc], employees: 30 [")
+	assert.Contains(t, retained[0], "(truncated)")
+	assert.Equal(t, importReportSampleSize, strings.Count(retained[0], "pod-"))
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
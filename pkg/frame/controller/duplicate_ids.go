@@ -0,0 +1,129 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WithDuplicateIdPolicy sets the DuplicateIdPolicy, DuplicateIdPolicyPick by default
+func WithDuplicateIdPolicy(policy DuplicateIdPolicy) Option {
+	return func(r *Consist) {
+		r.duplicateIdPolicy = policy
+	}
+}
+
+// DuplicateIdError is returned if duplicated ids found with DuplicateIdPolicyFail, employer is requeued after a fixed
+// interval
+type DuplicateIdError struct {
+	EmployerIds []string
+	EmployeeIds []string
+}
+
+func (e *DuplicateIdError) Error() string {
+	return fmt.Sprintf("duplicated ids found, employer: %v, employees: %v", e.EmployerIds, e.EmployeeIds)
+}
+
+// dedupeById builds map of items by id, item with the smallest sort key is picked if more than one share an id so
+// that the pick doesn't depend on order of items. Ids shared are returned sorted.
+func dedupeById[T any](items []T, id func(T) string, sortKey func(T) string) (map[string]T, []string) {
+	itemMap := make(map[string]T, len(items))
+	var duplicated sets.String
+	for _, item := range items {
+		itemId := id(item)
+		picked, exist := itemMap[itemId]
+		if !exist {
+			itemMap[itemId] = item
+			continue
+		}
+		if duplicated == nil {
+			duplicated = sets.NewString()
+		}
+		duplicated.Insert(itemId)
+		if sortKey(item) < sortKey(picked) {
+			itemMap[itemId] = item
+		}
+	}
+	return itemMap, duplicated.List()
+}
+
+func employerSortKey(employer IEmployer) string {
+	statuses, _ := json.Marshal(employer.GetEmployerStatuses())
+	return string(statuses)
+}
+
+func employeeSortKey(employee IEmployee) string {
+	statuses, _ := json.Marshal(employee.GetEmployeeStatuses())
+	return employee.GetEmployeeName() + "/" + string(statuses)
+}
+
+func mergeDuplicatedIds(expect, current []string) []string {
+	if len(expect) == 0 && len(current) == 0 {
+		return nil
+	}
+	return sets.NewString(expect...).Insert(current...).List()
+}
+
+// handleDuplicateIds reports duplicated ids via event, and condition of Service employer if StatusRecordOptions not
+// implemented. DuplicateIdError is returned if DuplicateIdPolicyFail.
+func (r *Consist) handleDuplicateIds(ctx context.Context, employer client.Object, employerIds, employeeIds []string) error {
+	duplicated := len(employerIds) != 0 || len(employeeIds) != 0
+	if duplicated {
+		r.recorder.Eventf(employer, corev1.EventTypeWarning, DuplicateIdsFound,
+			"duplicated ids found, employer: %v, employees: %v, policy: %s", employerIds, employeeIds, r.getDuplicateIdPolicy())
+	}
+
+	if svc, ok := employer.(*corev1.Service); ok {
+		if _, recordOptionsImplemented := r.adapter.(StatusRecordOptions); !recordOptionsImplemented {
+			svcOld := svc.DeepCopy()
+			if duplicated {
+				meta.SetStatusCondition(&svc.Status.Conditions, metav1.Condition{
+					Type:               DuplicateIdsConditionType(),
+					Status:             metav1.ConditionTrue,
+					ObservedGeneration: svc.Generation,
+					Reason:             "DuplicateIdsFound",
+					Message:            fmt.Sprintf("employer: %v, employees: %v", employerIds, employeeIds),
+				})
+			} else {
+				meta.RemoveStatusCondition(&svc.Status.Conditions, DuplicateIdsConditionType())
+			}
+			if err := r.patchServiceConditions(ctx, svcOld, svc); err != nil {
+				return fmt.Errorf("record duplicate ids condition failed, err: %w", err)
+			}
+		}
+	}
+
+	if duplicated && r.getDuplicateIdPolicy() == DuplicateIdPolicyFail {
+		return &DuplicateIdError{EmployerIds: employerIds, EmployeeIds: employeeIds}
+	}
+	return nil
+}
+
+func (r *Consist) getDuplicateIdPolicy() DuplicateIdPolicy {
+	if r.duplicateIdPolicy == DuplicateIdPolicyFail {
+		return DuplicateIdPolicyFail
+	}
+	return DuplicateIdPolicyPick
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Errors returned by adapter can be one of the types below(wrapped is ok) to decide how employer is requeued,
+// other errors are returned to controller-runtime and requeued with exponential backoff.
+
+// ErrThrottled means backend provider throttled the call, employer is requeued after RetryAfter.
+// Exponential backoff is used if RetryAfter not set.
+type ErrThrottled struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ErrThrottled) Error() string {
+	return fmt.Sprintf("throttled, retry after %s: %v", e.RetryAfter, e.Err)
+}
+
+func (e *ErrThrottled) Unwrap() error {
+	return e.Err
+}
+
+// ErrTerminal means retrying won't help, like invalid spec of employer, employer isn't requeued until it changed.
+// For Service employer, TerminalErrorConditionType is recorded if StatusRecordOptions not implemented.
+type ErrTerminal struct {
+	Err error
+}
+
+func (e *ErrTerminal) Error() string {
+	return fmt.Sprintf("terminal: %v", e.Err)
+}
+
+func (e *ErrTerminal) Unwrap() error {
+	return e.Err
+}
+
+// ErrConflict means resource on backend provider modified concurrently, employer is requeued right away(subject to
+// rate limiter of controller). Conflict errors of kube-apiserver are classified as ErrConflict too.
+type ErrConflict struct {
+	Err error
+}
+
+func (e *ErrConflict) Error() string {
+	return fmt.Sprintf("conflict: %v", e.Err)
+}
+
+func (e *ErrConflict) Unwrap() error {
+	return e.Err
+}
+
+// ErrNotFound means resource on backend provider not found, like deleted out of band after GetCurrent*, employer is
+// requeued right away to rebuild current state.
+type ErrNotFound struct {
+	Err error
+}
+
+func (e *ErrNotFound) Error() string {
+	return fmt.Sprintf("not found: %v", e.Err)
+}
+
+func (e *ErrNotFound) Unwrap() error {
+	return e.Err
+}
+
+// classifyError returns the typed error in err's chain, or err itself if none
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var throttled *ErrThrottled
+	var terminal *ErrTerminal
+	var conflict *ErrConflict
+	var notFound *ErrNotFound
+	var circuitOpen *CircuitOpenError
+	var duplicateId *DuplicateIdError
+	switch {
+	case errors.As(err, &terminal):
+		return terminal
+	case errors.As(err, &throttled):
+		return throttled
+	case errors.As(err, &circuitOpen):
+		return &ErrThrottled{RetryAfter: circuitOpen.RetryAfter, Err: err}
+	case errors.As(err, &duplicateId):
+		return &ErrThrottled{RetryAfter: duplicateIdRetryAfter, Err: err}
+	case errors.As(err, &conflict):
+		return conflict
+	case errors.As(err, &notFound):
+		return notFound
+	case apierrors.IsConflict(err):
+		return &ErrConflict{Err: err}
+	}
+	return err
+}
+
+// resultOfError maps err to the result of Reconcile, returned error is nil if err classified
+func resultOfError(result reconcile.Result, err error) (reconcile.Result, error) {
+	switch classified := classifyError(err).(type) {
+	case nil:
+		return result, nil
+	case *ErrThrottled:
+		if classified.RetryAfter <= 0 {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{RequeueAfter: classified.RetryAfter}, nil
+	case *ErrTerminal:
+		return reconcile.Result{}, nil
+	case *ErrConflict, *ErrNotFound:
+		return reconcile.Result{Requeue: true}, nil
+	default:
+		return result, err
+	}
+}
+
+// recordServiceTerminalCondition records TerminalErrorConditionType if err is ErrTerminal, and erases it once
+// reconcile succeeded
+func (r *Consist) recordServiceTerminalCondition(ctx context.Context, svc *corev1.Service, err error) error {
+	svcOld := svc.DeepCopy()
+	var terminal *ErrTerminal
+	switch {
+	case err == nil:
+		meta.RemoveStatusCondition(&svc.Status.Conditions, TerminalErrorConditionType())
+	case errors.As(err, &terminal):
+		meta.SetStatusCondition(&svc.Status.Conditions, metav1.Condition{
+			Type:               TerminalErrorConditionType(),
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: svc.Generation,
+			Reason:             "TerminalError",
+			Message:            err.Error(),
+		})
+	default:
+		return nil
+	}
+	return r.patchServiceConditions(ctx, svcOld, svc)
+}
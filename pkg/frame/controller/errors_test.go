@@ -0,0 +1,53 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestResultOfError(t *testing.T) {
+	cause := fmt.Errorf("cause")
+	cases := []struct {
+		name      string
+		err       error
+		result    reconcile.Result
+		returnErr bool
+	}{
+		{"nil", nil, reconcile.Result{}, false},
+		{"generic", cause, reconcile.Result{}, true},
+		{"throttled", fmt.Errorf("syncCreate failed, err: %w", &ErrThrottled{RetryAfter: time.Minute, Err: cause}),
+			reconcile.Result{RequeueAfter: time.Minute}, false},
+		{"throttled without retry after", &ErrThrottled{Err: cause}, reconcile.Result{}, true},
+		{"circuit open", &CircuitOpenError{RetryAfter: time.Second}, reconcile.Result{RequeueAfter: time.Second}, false},
+		{"duplicate ids", fmt.Errorf("wrapped: %w", &DuplicateIdError{EmployeeIds: []string{"pod-a"}}),
+			reconcile.Result{RequeueAfter: duplicateIdRetryAfter}, false},
+		{"terminal", fmt.Errorf("wrapped: %w", &ErrTerminal{Err: cause}), reconcile.Result{}, false},
+		{"conflict", &ErrConflict{Err: cause}, reconcile.Result{Requeue: true}, false},
+		{"not found", &ErrNotFound{Err: cause}, reconcile.Result{Requeue: true}, false},
+	}
+	for _, c := range cases {
+		result, err := resultOfError(reconcile.Result{}, c.err)
+		assert.Equal(t, c.result, result, c.name)
+		assert.Equal(t, c.returnErr, err != nil, c.name)
+	}
+}
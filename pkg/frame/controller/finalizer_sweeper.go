@@ -0,0 +1,341 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"kusionstack.io/kube-api/apps/v1alpha1"
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+const defaultFinalizerSweepInterval = 10 * time.Minute
+
+// FinalizerSweepOptions configures the sweeper of stale LifecycleFinalizers and ExpectedFinalizers on pods, whose
+// employer no longer exists or no longer selects the pod. Stale ones block pod deletion or operating indefinitely.
+// ExpectedFinalizers are removed only if key and value are exactly those generated for an employer of the adapter.
+// LifecycleFinalizers without ExpectedFinalizer keys are resolved by hashing names of employers in the namespace of pod,
+// those matching no employer are left untouched.
+// Only works for adapters following PodOpsLifecycle with Pod as employee and implementing ReconcileLifecycleOptions,
+// multi cluster is not supported.
+type FinalizerSweepOptions struct {
+	// Interval is the interval between two sweeps, 10 minutes by default
+	Interval time.Duration
+	// DryRun only logs finalizers that would be removed
+	DryRun bool
+}
+
+// WithFinalizerSweeper enables the sweeper of stale finalizers on pods, see FinalizerSweepOptions
+func WithFinalizerSweeper(options FinalizerSweepOptions) Option {
+	return func(r *Consist) {
+		if options.Interval <= 0 {
+			options.Interval = defaultFinalizerSweepInterval
+		}
+		r.finalizerSweepOptions = &options
+	}
+}
+
+var _ manager.Runnable = &finalizerSweeper{}
+
+type finalizerSweeper struct {
+	*Consist
+	lifecycleOptions ReconcileLifecycleOptions
+	options          FinalizerSweepOptions
+	employerKind     string
+	employerListGVK  schema.GroupVersionKind
+}
+
+// sweptEmployer caches employer state during one sweep
+type sweptEmployer struct {
+	exist bool
+	// expectedFlzKey is the expected finalizer key generated from employer got, without kind if got without TypeMeta
+	expectedFlzKey string
+	// finalizers of deleting employer are left to Reconcile
+	deleting bool
+	selected sets.String
+	// recorded are employees whose lifecycle finalizers recorded by Reconcile, left to Reconcile
+	recorded sets.String
+}
+
+// sweptNamespace caches employers of a namespace during one sweep
+type sweptNamespace struct {
+	name      string
+	employers map[types.NamespacedName]*sweptEmployer
+	// lifecycleFlzOwners maps lifecycle finalizers to names of employers in namespace, listed once needed
+	lifecycleFlzOwners map[string]string
+}
+
+func newFinalizerSweeper(r *Consist) (*finalizerSweeper, error) {
+	lifecycleOptions, ok := r.adapter.(ReconcileLifecycleOptions)
+	if !ok || !lifecycleOptions.FollowPodOpsLifeCycle() {
+		return nil, fmt.Errorf("adapter %s doesn't follow PodOpsLifecycle", r.adapter.GetControllerName())
+	}
+	if watchOptions, ok := r.adapter.(ReconcileWatchOptions); ok && !isPod(watchOptions.NewEmployee()) {
+		return nil, fmt.Errorf("employee of adapter %s is not Pod", r.adapter.GetControllerName())
+	}
+	if _, ok := r.adapter.(MultiClusterOptions); ok {
+		return nil, fmt.Errorf("finalizer sweeper doesn't support multi cluster")
+	}
+
+	gvk, err := apiutil.GVKForObject(r.newEmployer(), r.scheme)
+	if err != nil {
+		return nil, err
+	}
+	return &finalizerSweeper{
+		Consist:          r,
+		lifecycleOptions: lifecycleOptions,
+		options:          *r.finalizerSweepOptions,
+		employerKind:     gvk.Kind,
+		employerListGVK:  gvk.GroupVersion().WithKind(gvk.Kind + "List"),
+	}, nil
+}
+
+func (s *finalizerSweeper) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, s.sweep, s.options.Interval)
+	return nil
+}
+
+// sweep lists pods namespace by namespace, bounding pods held in one sweep step
+func (s *finalizerSweeper) sweep(ctx context.Context) {
+	logger := s.logger.WithValues("finalizerSweeper", "sweep", "dryRun", s.options.DryRun)
+
+	var namespaceList corev1.NamespaceList
+	if err := s.Client.List(ctx, &namespaceList); err != nil {
+		logger.Error(err, "list namespaces failed")
+		return
+	}
+	for _, namespace := range namespaceList.Items {
+		if err := s.sweepNamespace(ctx, namespace.Name); err != nil {
+			logger.Error(err, "sweep namespace failed", "namespace", namespace.Name)
+		}
+	}
+}
+
+func (s *finalizerSweeper) sweepNamespace(ctx context.Context, namespace string) error {
+	var podList corev1.PodList
+	if err := s.Client.List(ctx, &podList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("list pods failed, err: %w", err)
+	}
+
+	swept := &sweptNamespace{name: namespace, employers: make(map[types.NamespacedName]*sweptEmployer)}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if err := s.sweepPod(ctx, pod, swept); err != nil {
+			s.logger.Error(err, "sweep pod failed", "pod", pod.Namespace+"/"+pod.Name)
+		}
+	}
+	return nil
+}
+
+func (s *finalizerSweeper) sweepPod(ctx context.Context, pod *corev1.Pod, swept *sweptNamespace) error {
+	var availableExpectedFlzs v1alpha1.PodAvailableConditions
+	if anno := pod.GetAnnotations()[v1alpha1.PodAvailableConditionsAnnotation]; anno != "" {
+		if err := json.Unmarshal([]byte(anno), &availableExpectedFlzs); err != nil {
+			return err
+		}
+	}
+
+	// resolve expected finalizers back to employers, finalizers of stale ones are stale too
+	var staleKeys []string
+	staleFlzs := sets.NewString()
+	referencedFlzs := sets.NewString()
+	// only entries exactly as generated for employers of this adapter are resolved, others might be written by other
+	// controllers or installs
+	for key, flz := range availableExpectedFlzs.ExpectedFinalizers {
+		kind, ns, name, ok := parseLifecycleFinalizerKey(key)
+		if !ok || (kind != "" && kind != s.employerKind) || ns != pod.Namespace ||
+			flz != utils.GenerateLifecycleFinalizer(name) {
+			referencedFlzs.Insert(flz)
+			continue
+		}
+		employer, err := s.getSweptEmployer(ctx, types.NamespacedName{Namespace: ns, Name: name}, swept)
+		if err != nil {
+			return err
+		}
+		// keys without kind match employers of any kind, only those generated from an existing employer are resolved
+		if kind == "" && (!employer.exist || employer.expectedFlzKey != key) {
+			referencedFlzs.Insert(flz)
+			continue
+		}
+		if employer.exist && (employer.deleting || employer.selected.Has(pod.Name)) {
+			referencedFlzs.Insert(flz)
+			continue
+		}
+		staleKeys = append(staleKeys, key)
+		staleFlzs.Insert(flz)
+	}
+
+	// resolve lifecycle finalizers without expected finalizer keys back to employers by hashing their names
+	for _, flz := range pod.GetFinalizers() {
+		if !strings.HasPrefix(flz, v1alpha1.PodOperationProtectionFinalizerPrefix+"/") ||
+			staleFlzs.Has(flz) || referencedFlzs.Has(flz) {
+			continue
+		}
+		stale, err := s.isStaleLifecycleFinalizer(ctx, pod, flz, swept)
+		if err != nil {
+			return err
+		}
+		if stale {
+			staleFlzs.Insert(flz)
+		}
+	}
+
+	var finalizers, staleFinalizers []string
+	for _, flz := range pod.GetFinalizers() {
+		if staleFlzs.Has(flz) && !referencedFlzs.Has(flz) {
+			staleFinalizers = append(staleFinalizers, flz)
+			continue
+		}
+		finalizers = append(finalizers, flz)
+	}
+
+	if len(staleKeys) == 0 && len(staleFinalizers) == 0 {
+		return nil
+	}
+	if s.options.DryRun {
+		s.logger.Info("stale finalizers would be removed", "pod", pod.Namespace+"/"+pod.Name,
+			"expectedFinalizerKeys", staleKeys, "finalizers", staleFinalizers)
+		return nil
+	}
+
+	patch := client.MergeFromWithOptions(pod.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	for _, key := range staleKeys {
+		delete(availableExpectedFlzs.ExpectedFinalizers, key)
+	}
+	if len(staleKeys) != 0 {
+		annoAvailableExpectedFlzs, err := json.Marshal(availableExpectedFlzs)
+		if err != nil {
+			return err
+		}
+		pod.Annotations[v1alpha1.PodAvailableConditionsAnnotation] = string(annoAvailableExpectedFlzs)
+	}
+	pod.SetFinalizers(finalizers)
+	if err := s.Client.Patch(ctx, pod, patch); err != nil {
+		return err
+	}
+	s.recorder.Eventf(pod, corev1.EventTypeNormal, StaleFinalizersRemoved,
+		"stale finalizers of %s removed, expected finalizer keys: %v, finalizers: %v",
+		s.adapter.GetControllerName(), staleKeys, staleFinalizers)
+	return nil
+}
+
+// isStaleLifecycleFinalizer returns true if flz is lifecycle finalizer of an employer of this adapter in namespace of
+// pod, which is managed and no longer selects pod. Lifecycle finalizers of no employer found might be others', and are
+// not stale.
+func (s *finalizerSweeper) isStaleLifecycleFinalizer(ctx context.Context, pod *corev1.Pod, flz string, swept *sweptNamespace) (bool, error) {
+	if swept.lifecycleFlzOwners == nil {
+		owners, err := s.listLifecycleFinalizerOwners(ctx, swept.name)
+		if err != nil {
+			return false, err
+		}
+		swept.lifecycleFlzOwners = owners
+	}
+	name, ok := swept.lifecycleFlzOwners[flz]
+	if !ok {
+		return false, nil
+	}
+	employer, err := s.getSweptEmployer(ctx, types.NamespacedName{Namespace: swept.name, Name: name}, swept)
+	if err != nil {
+		return false, err
+	}
+	return employer.exist && !employer.deleting && !employer.selected.Has(pod.Name) && !employer.recorded.Has(pod.Name), nil
+}
+
+// listLifecycleFinalizerOwners maps lifecycle finalizers of employers in namespace to their names
+func (s *finalizerSweeper) listLifecycleFinalizerOwners(ctx context.Context, namespace string) (map[string]string, error) {
+	obj, err := s.scheme.New(s.employerListGVK)
+	if err != nil {
+		return nil, err
+	}
+	employerList, ok := obj.(client.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a list", s.employerListGVK)
+	}
+	if err = s.Client.List(ctx, employerList, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("list employers failed, err: %w", err)
+	}
+	owners := make(map[string]string)
+	err = meta.EachListItem(employerList, func(item runtime.Object) error {
+		employer, ok := item.(client.Object)
+		if !ok {
+			return fmt.Errorf("employer %T is not client.Object", item)
+		}
+		owners[utils.GenerateLifecycleFinalizer(employer.GetName())] = employer.GetName()
+		return nil
+	})
+	return owners, err
+}
+
+func (s *finalizerSweeper) getSweptEmployer(ctx context.Context, key types.NamespacedName,
+	swept *sweptNamespace) (*sweptEmployer, error) {
+	if employer, ok := swept.employers[key]; ok {
+		return employer, nil
+	}
+
+	employer := s.newEmployer()
+	err := s.Client.Get(ctx, key, employer)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	sweptEmployer := &sweptEmployer{selected: sets.NewString(), recorded: sets.NewString()}
+	if err == nil {
+		_, watchOptionsImplemented := s.adapter.(ReconcileWatchOptions)
+		sweptEmployer.exist = watchOptionsImplemented || doPredicate(employer)
+		sweptEmployer.expectedFlzKey = utils.GenerateLifecycleFinalizerKey(employer)
+		sweptEmployer.deleting = !employer.GetDeletionTimestamp().IsZero()
+		sweptEmployer.recorded.Insert(splitRecordedNames(employer.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey()])...)
+	}
+	if sweptEmployer.exist && !sweptEmployer.deleting {
+		selected, err := s.lifecycleOptions.GetSelectedEmployeeNames(ctx, employer)
+		if err != nil {
+			return nil, err
+		}
+		sweptEmployer.selected.Insert(selected...)
+	}
+	swept.employers[key] = sweptEmployer
+	return sweptEmployer, nil
+}
+
+// parseLifecycleFinalizerKey parses key generated by utils.GenerateLifecycleFinalizerKey, kind might be empty. Keys of
+// other installs are not ok.
+func parseLifecycleFinalizerKey(key string) (kind, namespace, name string, ok bool) {
+	key, ok = utils.TrimLifecycleFinalizerKeyInstall(key)
+	if !ok {
+		return "", "", "", false
+	}
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
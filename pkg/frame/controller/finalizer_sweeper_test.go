@@ -0,0 +1,149 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/kube-api/apps/v1alpha1"
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+func TestParseLifecycleFinalizerKeyOfInstalls(t *testing.T) {
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "svc"}}
+	svc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+	defaultKey := utils.GenerateLifecycleFinalizerKey(svc)
+	defaultFlz := utils.GenerateLifecycleFinalizer(svc.Name)
+
+	require.NoError(t, utils.SetInstallName("canary"))
+	defer func() {
+		_ = utils.SetInstallName("")
+	}()
+	canaryKey := utils.GenerateLifecycleFinalizerKey(svc)
+	assert.NotEqual(t, defaultKey, canaryKey)
+	assert.NotEqual(t, defaultFlz, utils.GenerateLifecycleFinalizer(svc.Name))
+
+	kind, ns, name, ok := parseLifecycleFinalizerKey(canaryKey)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"Service", "default", "svc"}, []string{kind, ns, name})
+	_, _, _, ok = parseLifecycleFinalizerKey(defaultKey)
+	assert.False(t, ok)
+
+	require.NoError(t, utils.SetInstallName(""))
+	_, _, _, ok = parseLifecycleFinalizerKey(canaryKey)
+	assert.False(t, ok)
+	_, _, _, ok = parseLifecycleFinalizerKey(defaultKey)
+	assert.True(t, ok)
+}
+
+func TestSweepLifecycleFinalizersWithoutKey(t *testing.T) {
+	ctx := context.Background()
+	adapter := newMemoryAdapter(nil)
+	adapter.followLifecycle = true
+	adapter.selected = []string{"pod-selected"}
+	svc := newTestService("svc", nil)
+	svc.Labels = map[string]string{v1alpha1.ControlledByKusionStackLabelKey: "true"}
+	flz := utils.GenerateLifecycleFinalizer(svc.Name)
+	unknownFlz := utils.GenerateLifecycleFinalizer("not-exist")
+	newPod := func(name string, finalizers ...string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Finalizers: finalizers}}
+	}
+	objs := []client.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		svc,
+		newPod("pod-stale", flz, "other"),
+		newPod("pod-selected", flz),
+		newPod("pod-unknown", unknownFlz),
+	}
+	s, err := newFinalizerSweeper(newFakeConsist(adapter, objs, WithFinalizerSweeper(FinalizerSweepOptions{})))
+	require.NoError(t, err)
+
+	s.sweep(ctx)
+
+	finalizersOf := func(name string) []string {
+		pod := &corev1.Pod{}
+		require.NoError(t, s.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, pod))
+		return pod.Finalizers
+	}
+	assert.Equal(t, []string{"other"}, finalizersOf("pod-stale"))
+	assert.Equal(t, []string{flz}, finalizersOf("pod-selected"))
+	assert.Equal(t, []string{unknownFlz}, finalizersOf("pod-unknown"))
+
+	// left to Reconcile while recorded
+	pod := &corev1.Pod{}
+	require.NoError(t, s.Get(ctx, client.ObjectKey{Namespace: "default", Name: "pod-stale"}, pod))
+	pod.Finalizers = append(pod.Finalizers, flz)
+	require.NoError(t, s.Update(ctx, pod))
+	svcLatest := &corev1.Service{}
+	require.NoError(t, s.Get(ctx, client.ObjectKeyFromObject(svc), svcLatest))
+	svcLatest.Annotations = map[string]string{lifecycleFinalizerRecordedAnnoKey(): "pod-stale"}
+	require.NoError(t, s.Update(ctx, svcLatest))
+	s.sweep(ctx)
+	assert.Equal(t, []string{"other", flz}, finalizersOf("pod-stale"))
+}
+
+func TestSweepKeepsForeignExpectedFinalizers(t *testing.T) {
+	ctx := context.Background()
+	adapter := newMemoryAdapter(nil)
+	adapter.followLifecycle = true
+	svc := newTestService("svc", nil)
+	svc.Labels = map[string]string{v1alpha1.ControlledByKusionStackLabelKey: "true"}
+	flz := utils.GenerateLifecycleFinalizer(svc.Name)
+	goneFlz := utils.GenerateLifecycleFinalizer("gone")
+	foreign := map[string]string{
+		// value not generated for the employer
+		"Service/default/other": "other.io/finalizer",
+		// kind not managed by the adapter
+		"Ingress/default/svc": flz,
+		// kind unknown, not generated from the employer got with kind
+		"/default/svc": flz,
+		// kind unknown and employer not found
+		"/default/gone": goneFlz,
+	}
+	expectedFlzs := map[string]string{"Service/default/svc": flz}
+	for key, value := range foreign {
+		expectedFlzs[key] = value
+	}
+	anno, err := json.Marshal(v1alpha1.PodAvailableConditions{ExpectedFinalizers: expectedFlzs})
+	require.NoError(t, err)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "pod",
+		Annotations: map[string]string{v1alpha1.PodAvailableConditionsAnnotation: string(anno)},
+		Finalizers:  []string{goneFlz},
+	}}
+	objs := []client.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, svc, pod}
+	s, err := newFinalizerSweeper(newFakeConsist(adapter, objs, WithFinalizerSweeper(FinalizerSweepOptions{})))
+	require.NoError(t, err)
+
+	s.sweep(ctx)
+
+	require.NoError(t, s.Get(ctx, client.ObjectKeyFromObject(pod), pod))
+	var conditions v1alpha1.PodAvailableConditions
+	require.NoError(t, json.Unmarshal([]byte(pod.Annotations[v1alpha1.PodAvailableConditionsAnnotation]), &conditions))
+	// only the key of the employer no longer selecting pod removed
+	assert.Equal(t, foreign, conditions.ExpectedFinalizers)
+	assert.Equal(t, []string{goneFlz}, pod.Finalizers)
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"kusionstack.io/kube-utils/multicluster/clusterinfo"
+)
+
+const (
+	defaultGCInterval    = 10 * time.Minute
+	defaultGCSafetyDelay = 30 * time.Minute
+)
+
+// GCOptions configures the garbage collector of resources on backend provider whose employer no longer exists,
+// like the employer force-deleted while controller down. Only works for adapters implementing ManagedEmployerLister.
+type GCOptions struct {
+	// Interval is the interval between two sweeps, 10 minutes by default
+	Interval time.Duration
+	// SafetyDelay is how long an employer should keep missing before its resources collected, 30 minutes by default
+	SafetyDelay time.Duration
+	// DryRun only logs resources that would be collected
+	DryRun bool
+}
+
+// WithGarbageCollection enables the garbage collector, see GCOptions
+func WithGarbageCollection(options GCOptions) Option {
+	return func(r *Consist) {
+		if options.Interval <= 0 {
+			options.Interval = defaultGCInterval
+		}
+		if options.SafetyDelay <= 0 {
+			options.SafetyDelay = defaultGCSafetyDelay
+		}
+		r.gcOptions = &options
+	}
+}
+
+var _ manager.Runnable = &garbageCollector{}
+
+// garbageCollector sweeps resources on backend provider periodically, collecting those whose employer keeps missing
+// longer than SafetyDelay via DeleteEmployees/DeleteEmployer of adapter.
+type garbageCollector struct {
+	*Consist
+	lister  ManagedEmployerLister
+	options GCOptions
+
+	// missingSince records when an employer first found missing, only accessed by sweep
+	missingSince map[types.NamespacedName]time.Time
+}
+
+func newGarbageCollector(r *Consist) (*garbageCollector, error) {
+	lister, ok := r.adapter.(ManagedEmployerLister)
+	if !ok {
+		return nil, fmt.Errorf("adapter %s doesn't implement ManagedEmployerLister", r.adapter.GetControllerName())
+	}
+	return &garbageCollector{
+		Consist:      r,
+		lister:       lister,
+		options:      *r.gcOptions,
+		missingSince: make(map[types.NamespacedName]time.Time),
+	}, nil
+}
+
+func (g *garbageCollector) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, g.sweep, g.options.Interval)
+	return nil
+}
+
+func (g *garbageCollector) sweep(ctx context.Context) {
+	logger := g.logger.WithValues("gc", "sweep", "dryRun", g.options.DryRun)
+
+	employers, err := g.lister.ListManagedEmployers(ctx)
+	if err != nil {
+		logger.Error(err, "list managed employers failed")
+		return
+	}
+
+	listed := make(map[types.NamespacedName]bool, len(employers))
+	for _, employer := range employers {
+		key := types.NamespacedName{Namespace: employer.GetNamespace(), Name: employer.GetName()}
+		if policy := getDeletionPolicy(employer); policy != DeletionPolicyDelete {
+			logger.V(5).Info("resources retained, skipped", "employer", key.String(), "policy", policy)
+			continue
+		}
+		listed[key] = true
+
+		exist, err := g.employerExist(ctx, key)
+		if err != nil {
+			logger.Error(err, "get employer failed", "employer", key.String())
+			continue
+		}
+		if exist {
+			delete(g.missingSince, key)
+			continue
+		}
+
+		since, ok := g.missingSince[key]
+		if !ok {
+			g.missingSince[key] = time.Now()
+			continue
+		}
+		if time.Since(since) < g.options.SafetyDelay {
+			continue
+		}
+
+		if g.options.DryRun {
+			logger.Info("employer missing, resources would be collected", "employer", key.String(), "missingSince", since)
+			garbageCollected.WithLabelValues(g.adapter.GetControllerName(), "true").Inc()
+			continue
+		}
+		if err = g.collect(ctx, employer); err != nil {
+			logger.Error(err, "collect resources of missing employer failed", "employer", key.String())
+			continue
+		}
+		logger.Info("resources of missing employer collected", "employer", key.String())
+		garbageCollected.WithLabelValues(g.adapter.GetControllerName(), "false").Inc()
+		delete(g.missingSince, key)
+	}
+
+	for key := range g.missingSince {
+		if !listed[key] {
+			delete(g.missingSince, key)
+		}
+	}
+}
+
+func (g *garbageCollector) employerExist(ctx context.Context, key types.NamespacedName) (bool, error) {
+	employer := g.newEmployer()
+	var err error
+	if _, ok := g.adapter.(MultiClusterOptions); ok {
+		err = g.Client.Get(clusterinfo.WithCluster(ctx, clusterinfo.Fed), key, employer)
+	} else {
+		err = g.Client.Get(ctx, key, employer)
+	}
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// collect deletes resources related to missing employer, employer is the one rebuilt by ListManagedEmployers and is
+// marked as deleting before passed to adapter. Resources retained by DeletionPolicyRetain/DeletionPolicyOrphan are
+// never collected.
+func (g *garbageCollector) collect(ctx context.Context, employer client.Object) error {
+	if policy := getDeletionPolicy(employer); policy != DeletionPolicyDelete {
+		return fmt.Errorf("resources retained by deletion policy %s", policy)
+	}
+	now := metav1.Now()
+	employer.SetDeletionTimestamp(&now)
+
+	currentEmployees, err := g.provider.GetCurrentEmployee(ctx, employer)
+	if err != nil {
+		return fmt.Errorf("get current employees failed, err: %w", err)
+	}
+	toCudEmployees, err := g.diffEmployees(employer, nil, currentEmployees)
+	if err != nil {
+		return fmt.Errorf("diff employees failed, err: %w", err)
+	}
+	_, failDelete, err := g.provider.DeleteEmployees(ctx, employer, toCudEmployees.ToDelete)
+	if err != nil {
+		return fmt.Errorf("delete employees failed, err: %w", err)
+	}
+	if len(failDelete) != 0 {
+		return fmt.Errorf("delete employees failed, failed: %v", employeeIds(failDelete))
+	}
+
+	currentEmployer, err := g.provider.GetCurrentEmployer(ctx, employer)
+	if err != nil {
+		return fmt.Errorf("get current employer failed, err: %w", err)
+	}
+	toCudEmployer, err := g.diffEmployer(employer, nil, currentEmployer)
+	if err != nil {
+		return fmt.Errorf("diff employer failed, err: %w", err)
+	}
+	_, failDeleteEmployer, err := g.provider.DeleteEmployer(ctx, employer, toCudEmployer.ToDelete)
+	if err != nil {
+		return fmt.Errorf("delete employer failed, err: %w", err)
+	}
+	if len(failDeleteEmployer) != 0 {
+		return fmt.Errorf("delete employer failed, failed: %v", employerIds(failDeleteEmployer))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+// gcAdapter rebuilds employers from ids of employer resources in memory, carrying policy marked
+type gcAdapter struct {
+	*memoryAdapter
+	retained map[string]DeletionPolicy
+}
+
+var _ ManagedEmployerLister = &gcAdapter{}
+
+func newGCAdapter(employerIds []string, employeeNames ...string) *gcAdapter {
+	return &gcAdapter{memoryAdapter: newMemoryAdapter(employerIds, employeeNames...), retained: map[string]DeletionPolicy{}}
+}
+
+func (g *gcAdapter) ListManagedEmployers(ctx context.Context) ([]client.Object, error) {
+	var employers []client.Object
+	for _, id := range g.employerIds() {
+		var annos map[string]string
+		if policy, ok := g.retained[id]; ok {
+			annos = map[string]string{DeletionPolicyAnnoKey(): string(policy)}
+		}
+		employers = append(employers, newTestService(id, annos))
+	}
+	return employers, nil
+}
+
+func (g *gcAdapter) MarkRetained(ctx context.Context, employer client.Object, policy DeletionPolicy) error {
+	g.retained[employer.GetName()] = policy
+	return nil
+}
+
+func TestGarbageCollectorSafetyDelay(t *testing.T) {
+	ctx := context.Background()
+	adapter := newGCAdapter([]string{"gone"}, "pod-a")
+	g, err := newGarbageCollector(newFakeConsist(adapter, nil, WithGarbageCollection(GCOptions{SafetyDelay: time.Hour})))
+	require.NoError(t, err)
+	key := types.NamespacedName{Namespace: "default", Name: "gone"}
+
+	g.sweep(ctx)
+	assert.Contains(t, g.missingSince, key)
+	assert.Equal(t, []string{"gone"}, adapter.employerIds())
+
+	g.missingSince[key] = time.Now().Add(-time.Hour + time.Minute)
+	g.sweep(ctx)
+	assert.Equal(t, []string{"gone"}, adapter.employerIds())
+	assert.Equal(t, []string{"pod-a"}, adapter.employeeIds())
+
+	g.missingSince[key] = time.Now().Add(-time.Hour - time.Second)
+	g.sweep(ctx)
+	assert.Empty(t, adapter.employerIds())
+	assert.Empty(t, adapter.employeeIds())
+	assert.NotContains(t, g.missingSince, key)
+}
+
+func TestGarbageCollectorSkipRetained(t *testing.T) {
+	for _, policy := range []DeletionPolicy{DeletionPolicyRetain, DeletionPolicyOrphan} {
+		t.Run(string(policy), func(t *testing.T) {
+			ctx := context.Background()
+			adapter := newGCAdapter([]string{"svc"}, "pod-a")
+			svc := newTestService("svc", map[string]string{DeletionPolicyAnnoKey(): string(policy)},
+				utils.GenerateCleanFinalizer())
+			r := newFakeConsist(adapter, []client.Object{svc}, WithGarbageCollection(GCOptions{SafetyDelay: time.Nanosecond}))
+			require.NoError(t, r.Delete(ctx, svc))
+			_, err := reconcileEmployer(t, r, svc)
+			require.NoError(t, err)
+			assert.Equal(t, policy, adapter.retained["svc"])
+
+			g, err := newGarbageCollector(r)
+			require.NoError(t, err)
+			for i := 0; i < 2; i++ {
+				g.sweep(ctx)
+			}
+			assert.Empty(t, g.missingSince)
+
+			rebuilt, err := adapter.ListManagedEmployers(ctx)
+			require.NoError(t, err)
+			assert.Error(t, g.collect(ctx, rebuilt[0]))
+			assert.Equal(t, []string{"svc"}, adapter.employerIds())
+			assert.Equal(t, []string{"pod-a"}, adapter.employeeIds())
+			assert.Empty(t, writeCalls(adapter.getCalls()))
+		})
+	}
+}
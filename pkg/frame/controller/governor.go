@@ -0,0 +1,326 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	defaultGovernorQPS              = 10
+	defaultGovernorBurst            = 20
+	defaultGovernorFailureThreshold = 5
+	defaultGovernorOpenDuration     = 10 * time.Second
+	defaultGovernorMaxOpenDuration  = 5 * time.Minute
+
+	// halfOpenProbeRetryAfter is how long calls wait while the single probe of half-open breaker in flight
+	halfOpenProbeRetryAfter = time.Second
+)
+
+// CallGovernorOptions configures a CallGovernor
+type CallGovernorOptions struct {
+	// Name identifies the governor in metrics, usually the backend provider or account
+	Name string
+	// QPS and Burst configure the token bucket shared by all calls governed, 10 and 20 by default
+	QPS   float64
+	Burst int
+	// FailureThreshold is the count of consecutive failed calls opening the circuit breaker, 5 by default. Only
+	// ErrThrottled, CallTimeoutError and errors not typed count as failed, see countsAsFailure.
+	FailureThreshold int
+	// IgnoreCallTimeouts stops CallTimeoutError counting as failed, for providers whose calls are expected to be slow
+	// now and then
+	IgnoreCallTimeouts bool
+	// OpenDuration is how long the circuit breaker keeps open once opened, 10 seconds by default. It's doubled each
+	// time the breaker reopened without any call succeeded in between, capped by MaxOpenDuration(5 minutes by default).
+	OpenDuration    time.Duration
+	MaxOpenDuration time.Duration
+}
+
+// CallGovernor governs calls to backend provider, GetCurrentEmployer/GetCurrentEmployee and CUD methods of adapter,
+// combining a token bucket rate limiter with a circuit breaker opened on consecutive failed calls. Once OpenDuration
+// passed, the breaker turns half-open and lets a single probe call through, the others are rejected until it returns.
+// One CallGovernor can be shared by adapters whose backend provider shares one quota, via WithCallGovernor.
+type CallGovernor struct {
+	options CallGovernorOptions
+	limiter *rate.Limiter
+
+	mu sync.Mutex
+	// failures is the count of consecutive failed calls
+	failures int
+	// openUntil is when the breaker turns half-open, breaker is closed if zero
+	openUntil    time.Time
+	openDuration time.Duration
+	// probing is true while the probe of half-open breaker in flight
+	probing bool
+}
+
+// NewCallGovernor creates a CallGovernor, zero fields of options are set to default values
+func NewCallGovernor(options CallGovernorOptions) *CallGovernor {
+	if options.QPS <= 0 {
+		options.QPS = defaultGovernorQPS
+	}
+	if options.Burst <= 0 {
+		options.Burst = defaultGovernorBurst
+	}
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = defaultGovernorFailureThreshold
+	}
+	if options.OpenDuration <= 0 {
+		options.OpenDuration = defaultGovernorOpenDuration
+	}
+	if options.MaxOpenDuration < options.OpenDuration {
+		options.MaxOpenDuration = defaultGovernorMaxOpenDuration
+		if options.MaxOpenDuration < options.OpenDuration {
+			options.MaxOpenDuration = options.OpenDuration
+		}
+	}
+	return &CallGovernor{
+		options:      options,
+		limiter:      rate.NewLimiter(rate.Limit(options.QPS), options.Burst),
+		openDuration: options.OpenDuration,
+	}
+}
+
+// WithCallGovernor governs calls to backend provider of the adapter by governor, see CallGovernor.
+// Reconciles requeue after the breaker turns half-open instead of calling the adapter while the breaker is open.
+func WithCallGovernor(governor *CallGovernor) Option {
+	return func(r *Consist) {
+		r.governor = governor
+	}
+}
+
+// CircuitOpenError is returned instead of calling the adapter while the circuit breaker is open
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker of backend provider open, retry after %s", e.RetryAfter)
+}
+
+// retryAfter returns how long to wait before calling backend provider, zero if breaker closed or half-open without
+// probe in flight
+func (g *CallGovernor) retryAfter() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.retryAfterLocked()
+}
+
+func (g *CallGovernor) retryAfterLocked() time.Duration {
+	if g.openUntil.IsZero() {
+		return 0
+	}
+	if wait := time.Until(g.openUntil); wait > 0 {
+		return wait
+	}
+	if g.probing {
+		return halfOpenProbeRetryAfter
+	}
+	return 0
+}
+
+// acquire returns how long to wait if fn shouldn't be called, and whether the call is the probe of half-open breaker
+func (g *CallGovernor) acquire() (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if wait := g.retryAfterLocked(); wait > 0 {
+		return wait, false
+	}
+	if g.openUntil.IsZero() {
+		return 0, false
+	}
+	g.probing = true
+	return 0, true
+}
+
+// call calls fn if breaker isn't open, waiting for a token of rate limiter firstly
+func (g *CallGovernor) call(ctx context.Context, fn func() error) error {
+	wait, probe := g.acquire()
+	if wait > 0 {
+		return &CircuitOpenError{RetryAfter: wait}
+	}
+	if err := g.limiter.Wait(ctx); err != nil {
+		if probe {
+			g.mu.Lock()
+			g.probing = false
+			g.mu.Unlock()
+		}
+		return err
+	}
+	err := fn()
+	g.record(err, probe)
+	return err
+}
+
+// countsAsFailure returns true if err is ErrThrottled, CallTimeoutError or not typed, which means backend provider
+// might be unhealthy, a hung provider above all. Typed errors other than ErrThrottled, cancellation of ctx and invalid
+// requests are caused by the call itself.
+func (g *CallGovernor) countsAsFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || apierrors.IsInvalid(err) || apierrors.IsBadRequest(err) {
+		return false
+	}
+	var timeout *CallTimeoutError
+	if errors.As(err, &timeout) {
+		return !g.options.IgnoreCallTimeouts
+	}
+	switch classifyError(err).(type) {
+	case *ErrThrottled:
+		return true
+	case *ErrTerminal, *ErrConflict, *ErrNotFound:
+		return false
+	default:
+		return true
+	}
+}
+
+func (g *CallGovernor) record(err error, probe bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if probe {
+		g.probing = false
+	}
+	if err != nil && !g.countsAsFailure(err) {
+		return
+	}
+
+	if err == nil {
+		if !g.openUntil.IsZero() {
+			circuitBreakerState.WithLabelValues(g.options.Name).Set(0)
+		}
+		g.failures = 0
+		g.openUntil = time.Time{}
+		g.openDuration = g.options.OpenDuration
+		return
+	}
+
+	if !probe && !g.openUntil.IsZero() {
+		// call started before breaker opened
+		return
+	}
+	g.failures++
+	if !probe && g.failures < g.options.FailureThreshold {
+		return
+	}
+	// probe failed in half-open state, reopen with backoff
+	if probe {
+		g.openDuration *= 2
+		if g.openDuration > g.options.MaxOpenDuration {
+			g.openDuration = g.options.MaxOpenDuration
+		}
+	}
+	g.openUntil = time.Now().Add(g.openDuration)
+	circuitBreakerState.WithLabelValues(g.options.Name).Set(1)
+	circuitBreakerOpened.WithLabelValues(g.options.Name).Inc()
+}
+
+// governedAdapter wraps calls to backend provider of adapter with CallGovernor, optional interfaces are still asserted
+// on the adapter wrapped. CUD methods with nothing to do are not governed.
+type governedAdapter struct {
+	ReconcileAdapter
+	governor *CallGovernor
+}
+
+func (a *governedAdapter) GetCurrentEmployer(ctx context.Context, employer client.Object) (current []IEmployer, err error) {
+	err = a.governor.call(ctx, func() error {
+		current, err = a.ReconcileAdapter.GetCurrentEmployer(ctx, employer)
+		return err
+	})
+	return current, err
+}
+
+func (a *governedAdapter) CreateEmployer(ctx context.Context, employer client.Object, toCreate []IEmployer) (succ, fail []IEmployer, err error) {
+	if len(toCreate) == 0 {
+		return a.ReconcileAdapter.CreateEmployer(ctx, employer, toCreate)
+	}
+	err = a.governor.call(ctx, func() error {
+		succ, fail, err = a.ReconcileAdapter.CreateEmployer(ctx, employer, toCreate)
+		return err
+	})
+	return succ, fail, err
+}
+
+func (a *governedAdapter) UpdateEmployer(ctx context.Context, employer client.Object, toUpdate []IEmployer) (succ, fail []IEmployer, err error) {
+	if len(toUpdate) == 0 {
+		return a.ReconcileAdapter.UpdateEmployer(ctx, employer, toUpdate)
+	}
+	err = a.governor.call(ctx, func() error {
+		succ, fail, err = a.ReconcileAdapter.UpdateEmployer(ctx, employer, toUpdate)
+		return err
+	})
+	return succ, fail, err
+}
+
+func (a *governedAdapter) DeleteEmployer(ctx context.Context, employer client.Object, toDelete []IEmployer) (succ, fail []IEmployer, err error) {
+	if len(toDelete) == 0 {
+		return a.ReconcileAdapter.DeleteEmployer(ctx, employer, toDelete)
+	}
+	err = a.governor.call(ctx, func() error {
+		succ, fail, err = a.ReconcileAdapter.DeleteEmployer(ctx, employer, toDelete)
+		return err
+	})
+	return succ, fail, err
+}
+
+func (a *governedAdapter) GetCurrentEmployee(ctx context.Context, employer client.Object) (current []IEmployee, err error) {
+	err = a.governor.call(ctx, func() error {
+		current, err = a.ReconcileAdapter.GetCurrentEmployee(ctx, employer)
+		return err
+	})
+	return current, err
+}
+
+func (a *governedAdapter) CreateEmployees(ctx context.Context, employer client.Object, toCreate []IEmployee) (succ, fail []IEmployee, err error) {
+	if len(toCreate) == 0 {
+		return a.ReconcileAdapter.CreateEmployees(ctx, employer, toCreate)
+	}
+	err = a.governor.call(ctx, func() error {
+		succ, fail, err = a.ReconcileAdapter.CreateEmployees(ctx, employer, toCreate)
+		return err
+	})
+	return succ, fail, err
+}
+
+func (a *governedAdapter) UpdateEmployees(ctx context.Context, employer client.Object, toUpdate []IEmployee) (succ, fail []IEmployee, err error) {
+	if len(toUpdate) == 0 {
+		return a.ReconcileAdapter.UpdateEmployees(ctx, employer, toUpdate)
+	}
+	err = a.governor.call(ctx, func() error {
+		succ, fail, err = a.ReconcileAdapter.UpdateEmployees(ctx, employer, toUpdate)
+		return err
+	})
+	return succ, fail, err
+}
+
+func (a *governedAdapter) DeleteEmployees(ctx context.Context, employer client.Object, toDelete []IEmployee) (succ, fail []IEmployee, err error) {
+	if len(toDelete) == 0 {
+		return a.ReconcileAdapter.DeleteEmployees(ctx, employer, toDelete)
+	}
+	err = a.governor.call(ctx, func() error {
+		succ, fail, err = a.ReconcileAdapter.DeleteEmployees(ctx, employer, toDelete)
+		return err
+	})
+	return succ, fail, err
+}
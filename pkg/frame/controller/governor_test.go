@@ -0,0 +1,114 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCallGovernorCircuitBreaker(t *testing.T) {
+	g := NewCallGovernor(CallGovernorOptions{
+		Name:             "test",
+		QPS:              1000,
+		FailureThreshold: 2,
+		OpenDuration:     50 * time.Millisecond,
+	})
+	ctx := context.Background()
+	failed := func() error { return &ErrThrottled{Err: fmt.Errorf("throttled")} }
+	called := 0
+	succeed := func() error { called++; return nil }
+
+	assert.Error(t, g.call(ctx, failed))
+	assert.Zero(t, g.retryAfter())
+	assert.Error(t, g.call(ctx, failed))
+	assert.NotZero(t, g.retryAfter())
+
+	err := g.call(ctx, succeed)
+	_, ok := err.(*CircuitOpenError)
+	assert.True(t, ok)
+	assert.Equal(t, 0, called)
+
+	// failed in half-open state, reopened with doubled duration
+	time.Sleep(60 * time.Millisecond)
+	assert.Error(t, g.call(ctx, failed))
+	assert.Greater(t, g.retryAfter(), 50*time.Millisecond)
+
+	time.Sleep(110 * time.Millisecond)
+	assert.NoError(t, g.call(ctx, succeed))
+	assert.Equal(t, 1, called)
+	assert.Zero(t, g.retryAfter())
+}
+
+func TestCallGovernorCountsOnlyTransientErrors(t *testing.T) {
+	g := NewCallGovernor(CallGovernorOptions{Name: "test", QPS: 1000, FailureThreshold: 1})
+	ctx := context.Background()
+	for _, err := range []error{
+		&ErrTerminal{Err: fmt.Errorf("invalid spec")},
+		&ErrNotFound{Err: fmt.Errorf("vip not found")},
+		fmt.Errorf("update vip: %w", &ErrConflict{Err: fmt.Errorf("modified")}),
+		context.Canceled,
+		apierrors.NewConflict(schema.GroupResource{Resource: "services"}, "svc", fmt.Errorf("modified")),
+		apierrors.NewBadRequest("bad request"),
+	} {
+		err := err
+		assert.Equal(t, err, g.call(ctx, func() error { return err }))
+		assert.Zero(t, g.retryAfter(), "%v shouldn't open the breaker", err)
+	}
+
+	assert.Error(t, g.call(ctx, func() error { return fmt.Errorf("connection reset") }))
+	assert.NotZero(t, g.retryAfter())
+}
+
+func TestCallGovernorSingleHalfOpenProbe(t *testing.T) {
+	g := NewCallGovernor(CallGovernorOptions{Name: "test", QPS: 1000, FailureThreshold: 1, OpenDuration: 20 * time.Millisecond})
+	ctx := context.Background()
+	assert.Error(t, g.call(ctx, func() error { return fmt.Errorf("unavailable") }))
+	time.Sleep(30 * time.Millisecond)
+
+	probing := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- g.call(ctx, func() error {
+			close(probing)
+			<-release
+			return nil
+		})
+	}()
+	<-probing
+
+	called := false
+	err := g.call(ctx, func() error { called = true; return nil })
+	var circuitOpen *CircuitOpenError
+	assert.True(t, errors.As(err, &circuitOpen))
+	assert.False(t, called)
+	assert.NotZero(t, g.retryAfter())
+
+	close(release)
+	assert.NoError(t, <-done)
+	assert.Zero(t, g.retryAfter())
+	assert.NoError(t, g.call(ctx, func() error { called = true; return nil }))
+	assert.True(t, called)
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// handleHookResult returns whether reconcile should stop after the hook and the result to return if so,
+// requeueAfter is updated to the minimum RequeueAfter of hooks called.
+func (r *Consist) handleHookResult(employer client.Object, hook string, hookResult HookResult, err error,
+	requeueAfter *time.Duration) (bool, reconcile.Result) {
+	if err != nil {
+		r.logger.Error(err, "reconcile hook failed", "hook", hook, "employer", employer.GetNamespace()+"/"+employer.GetName())
+		r.recorder.Eventf(employer, corev1.EventTypeWarning, ReconcileHookFailed,
+			"%s failed: %s", hook, err.Error())
+		return true, reconcile.Result{}
+	}
+
+	if hookResult.RequeueAfter > 0 && (*requeueAfter == 0 || hookResult.RequeueAfter < *requeueAfter) {
+		*requeueAfter = hookResult.RequeueAfter
+	}
+	if hookResult.Abort {
+		r.recorder.Eventf(employer, corev1.EventTypeNormal, ReconcileAbortedByHook,
+			"reconcile aborted by %s, requeue after: %s", hook, *requeueAfter)
+		return true, reconcile.Result{RequeueAfter: *requeueAfter}
+	}
+	return false, reconcile.Result{}
+}
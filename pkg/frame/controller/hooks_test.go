@@ -0,0 +1,117 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+// hookAdapter records hooks together with adapter calls, the hook named abortAt aborts and the one named failAt fails
+type hookAdapter struct {
+	*memoryAdapter
+	abortAt    string
+	failAt     string
+	finallyErr error
+}
+
+var _ ReconcileHooks = &hookAdapter{}
+var _ ReconcileFinallyHook = &hookAdapter{}
+
+var errHookFailed = errors.New("hook failed")
+
+func (h *hookAdapter) hook(name string) (HookResult, error) {
+	h.record(name)
+	if name == h.failAt {
+		return HookResult{}, errHookFailed
+	}
+	return HookResult{Abort: name == h.abortAt, RequeueAfter: time.Minute}, nil
+}
+
+func (h *hookAdapter) PreSyncEmployer(ctx context.Context, employer client.Object) (HookResult, error) {
+	return h.hook("PreSyncEmployer")
+}
+
+func (h *hookAdapter) PostSyncEmployer(ctx context.Context, employer client.Object, toCudEmployer ToCUDEmployer,
+	cudEmployerResults CUDEmployerResults) (HookResult, error) {
+	return h.hook("PostSyncEmployer")
+}
+
+func (h *hookAdapter) PostSyncEmployees(ctx context.Context, employer client.Object, toCudEmployees ToCUDEmployees,
+	cudEmployeeResults CUDEmployeeResults) (HookResult, error) {
+	return h.hook("PostSyncEmployees")
+}
+
+func (h *hookAdapter) Finally(ctx context.Context, employer client.Object, err error) {
+	h.record("Finally")
+	h.finallyErr = err
+}
+
+func TestReconcileHooks(t *testing.T) {
+	cases := []struct {
+		name        string
+		abortAt     string
+		failAt      string
+		expectCalls []string
+	}{
+		{
+			name: "finished",
+			expectCalls: []string{"PreSyncEmployer", "GetExpectedEmployer", "GetCurrentEmployer", "PostSyncEmployer",
+				"GetExpectedEmployee", "GetCurrentEmployee", "PostSyncEmployees", "Finally"},
+		},
+		{
+			name:        "aborted before employer got",
+			abortAt:     "PreSyncEmployer",
+			expectCalls: []string{"PreSyncEmployer", "Finally"},
+		},
+		{
+			name:        "failed before employees got",
+			failAt:      "PostSyncEmployer",
+			expectCalls: []string{"PreSyncEmployer", "GetExpectedEmployer", "GetCurrentEmployer", "PostSyncEmployer", "Finally"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			adapter := &hookAdapter{
+				memoryAdapter: newMemoryAdapter([]string{"svc"}, "pod-a"),
+				abortAt:       tc.abortAt,
+				failAt:        tc.failAt,
+			}
+			svc := newTestService("svc", nil, utils.GenerateCleanFinalizer())
+			r := newFakeConsist(adapter, []client.Object{svc})
+
+			result, err := reconcileEmployer(t, r, svc)
+			assert.Equal(t, tc.expectCalls, adapter.getCalls())
+			if tc.failAt != "" {
+				require.ErrorIs(t, err, errHookFailed)
+				assert.ErrorIs(t, adapter.finallyErr, errHookFailed)
+				return
+			}
+			require.NoError(t, err)
+			assert.NoError(t, adapter.finallyErr)
+			assert.Equal(t, time.Minute, result.RequeueAfter)
+		})
+	}
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+// markImportIfNewlyManaged marks employer as importing if it's newly managed, which means clean finalizer not added yet.
+// The mark is persisted together with clean finalizer in ensureEmployerCleanFlz.
+func (r *Consist) markImportIfNewlyManaged(employer client.Object) {
+	if _, ok := r.adapter.(ReconcileImportOptions); !ok {
+		return
+	}
+	if !employer.GetDeletionTimestamp().IsZero() {
+		return
+	}
+	for _, flz := range employer.GetFinalizers() {
+		if flz == utils.GenerateCleanFinalizer() || flz == generateOldCleanFlz(employer) {
+			return
+		}
+	}
+	annos := employer.GetAnnotations()
+	if annos == nil {
+		annos = make(map[string]string)
+	}
+	if annos[importStateAnnoKey()] != "" || annos[ImportConfirmedAnnoKey()] == "true" {
+		return
+	}
+	annos[importStateAnnoKey()] = importStatePending
+	employer.SetAnnotations(annos)
+}
+
+func isImportUnconfirmed(employer client.Object) bool {
+	return employer.GetAnnotations()[importStateAnnoKey()] != ""
+}
+
+// isImportBlocking returns whether import blocks convergence of employer not being deleted
+func (r *Consist) isImportBlocking(employer client.Object) bool {
+	if _, ok := r.adapter.(ReconcileImportOptions); !ok {
+		return false
+	}
+	return isImportUnconfirmed(employer) && employer.GetDeletionTimestamp().IsZero()
+}
+
+// reconcileImport adopts resources on backend provider for importing employer and waits for confirmation, or finishes
+// import at once if nothing to adopt. Returns true if import finished and normal convergence can go on.
+func (r *Consist) reconcileImport(ctx context.Context, employer client.Object) (bool, error) {
+	if !isImportUnconfirmed(employer) {
+		return true, nil
+	}
+	importOptions, ok := r.adapter.(ReconcileImportOptions)
+	if !ok {
+		return true, nil
+	}
+
+	if employer.GetAnnotations()[ImportConfirmedAnnoKey()] == "true" {
+		if err := r.finishImport(ctx, employer); err != nil {
+			return false, fmt.Errorf("patch import confirmed failed, err: %w", err)
+		}
+		r.recorder.Event(employer, corev1.EventTypeNormal, ImportConfirmed, "import confirmed, start converging")
+		return true, nil
+	}
+
+	if employer.GetAnnotations()[importStateAnnoKey()] == importStateAdopted {
+		return false, nil
+	}
+
+	currentEmployer, err := r.provider.GetCurrentEmployer(ctx, employer)
+	if err != nil {
+		return false, fmt.Errorf("get current employer failed, err: %w", err)
+	}
+	currentEmployees, err := r.provider.GetCurrentEmployee(ctx, employer)
+	if err != nil {
+		return false, fmt.Errorf("get current employees failed, err: %w", err)
+	}
+	if len(currentEmployer) == 0 && len(currentEmployees) == 0 {
+		if err = r.finishImport(ctx, employer); err != nil {
+			return false, fmt.Errorf("patch import skipped failed, err: %w", err)
+		}
+		r.recorder.Event(employer, corev1.EventTypeNormal, ImportSkipped,
+			"nothing to adopt on backend provider, start converging")
+		return true, nil
+	}
+
+	expectedEmployer, err := r.provider.GetExpectedEmployer(ctx, employer)
+	if err != nil {
+		return false, fmt.Errorf("get expect employer failed, err: %w", err)
+	}
+	expectedEmployees, err := r.provider.GetExpectedEmployee(ctx, employer)
+	if err != nil {
+		return false, fmt.Errorf("get expect employees failed, err: %w", err)
+	}
+	toCudEmployer, err := r.diffEmployer(employer, expectedEmployer, currentEmployer)
+	if err != nil {
+		return false, fmt.Errorf("diff employer failed, err: %w", err)
+	}
+	toCudEmployees, err := r.diffEmployees(employer, expectedEmployees, currentEmployees)
+	if err != nil {
+		return false, fmt.Errorf("diff employees failed, err: %w", err)
+	}
+
+	// only resources not adopted by former tries are adopted
+	adopted := r.importAdoptedIds(employer)
+	succAdoptEmployer, toAdoptEmployer := splitAdopted(currentEmployer, adopted.employers, IEmployer.GetEmployerId)
+	succAdoptEmployees, toAdoptEmployees := splitAdopted(currentEmployees, adopted.employees, IEmployee.GetEmployeeId)
+	var failAdoptEmployer []IEmployer
+	var failAdoptEmployees []IEmployee
+	if len(toAdoptEmployer) != 0 {
+		succ, fail, err := importOptions.AdoptEmployer(ctx, employer, toAdoptEmployer)
+		if err != nil {
+			return false, fmt.Errorf("adopt employer failed, err: %w", err)
+		}
+		adopted.employers.Insert(employerIds(succ)...)
+		succAdoptEmployer, failAdoptEmployer = append(succAdoptEmployer, succ...), fail
+	}
+	if len(toAdoptEmployees) != 0 {
+		succ, fail, err := importOptions.AdoptEmployees(ctx, employer, toAdoptEmployees)
+		if err != nil {
+			return false, fmt.Errorf("adopt employees failed, err: %w", err)
+		}
+		adopted.employees.Insert(employeeIds(succ)...)
+		succAdoptEmployees, failAdoptEmployees = append(succAdoptEmployees, succ...), fail
+	}
+
+	report := newImportReport(succAdoptEmployer, failAdoptEmployer, succAdoptEmployees, failAdoptEmployees,
+		toCudEmployer, toCudEmployees)
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return false, err
+	}
+
+	adoptFailedExist := len(failAdoptEmployer) > 0 || len(failAdoptEmployees) > 0
+	patch := client.MergeFrom(employer.DeepCopyObject().(client.Object))
+	annos := employer.GetAnnotations()
+	annos[importReportAnnoKey()] = string(reportBytes)
+	if !adoptFailedExist {
+		annos[importStateAnnoKey()] = importStateAdopted
+	}
+	employer.SetAnnotations(annos)
+	if err = r.patchEmployer(ctx, employer, patch); err != nil {
+		return false, fmt.Errorf("patch import report failed, err: %w", err)
+	}
+
+	if adoptFailedExist {
+		return false, fmt.Errorf("adopt failed exist, %d employer and %d employees, employer: %v, employees: %v",
+			report.Counts.FailAdoptedEmployers, report.Counts.FailAdoptedEmployees,
+			report.FailAdoptedEmployers, report.FailAdoptedEmployees)
+	}
+	r.forgetImportAdopted(employer)
+	r.recorder.Eventf(employer, corev1.EventTypeNormal, BackendResourcesAdopted,
+		"backend resources adopted, %d employer and %d employees, confirm via anno %s after checking %s",
+		len(succAdoptEmployer), len(succAdoptEmployees), ImportConfirmedAnnoKey(), importReportAnnoKey())
+	return false, nil
+}
+
+// finishImport removes import state and report from employer, so that normal convergence goes on
+func (r *Consist) finishImport(ctx context.Context, employer client.Object) error {
+	patch := client.MergeFrom(employer.DeepCopyObject().(client.Object))
+	annos := employer.GetAnnotations()
+	delete(annos, importStateAnnoKey())
+	delete(annos, importReportAnnoKey())
+	employer.SetAnnotations(annos)
+	if err := r.patchEmployer(ctx, employer, patch); err != nil {
+		return err
+	}
+	r.forgetImportAdopted(employer)
+	return nil
+}
+
+// importAdopted records ids adopted for an importing employer until all adopted. It's kept in memory only, resources
+// are adopted again after restart, which is harmless since adopting is idempotent.
+type importAdopted struct {
+	uid       types.UID
+	employers sets.String
+	employees sets.String
+}
+
+// importAdoptedIds returns ids adopted for employer by former tries
+func (r *Consist) importAdoptedIds(employer client.Object) *importAdopted {
+	key := types.NamespacedName{Namespace: employer.GetNamespace(), Name: employer.GetName()}
+	if value, ok := r.importAdopted.Load(key); ok && value.(*importAdopted).uid == employer.GetUID() {
+		return value.(*importAdopted)
+	}
+	adopted := &importAdopted{uid: employer.GetUID(), employers: sets.NewString(), employees: sets.NewString()}
+	r.importAdopted.Store(key, adopted)
+	return adopted
+}
+
+func (r *Consist) forgetImportAdopted(employer client.Object) {
+	r.importAdopted.Delete(types.NamespacedName{Namespace: employer.GetNamespace(), Name: employer.GetName()})
+}
+
+// splitAdopted splits items into those whose ids adopted and the others
+func splitAdopted[T any](items []T, adopted sets.String, id func(T) string) ([]T, []T) {
+	var done, rest []T
+	for _, item := range items {
+		if adopted.Has(id(item)) {
+			done = append(done, item)
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	return done, rest
+}
+
+// newImportReport counts ids of each list and keeps at most importReportSampleSize of them
+func newImportReport(succAdoptEmployer, failAdoptEmployer []IEmployer, succAdoptEmployees, failAdoptEmployees []IEmployee,
+	toCudEmployer ToCUDEmployer, toCudEmployees ToCUDEmployees) ImportReport {
+	report := ImportReport{
+		Counts: ImportReportCounts{
+			AdoptedEmployers:     len(succAdoptEmployer),
+			FailAdoptedEmployers: len(failAdoptEmployer),
+			AdoptedEmployees:     len(succAdoptEmployees),
+			FailAdoptedEmployees: len(failAdoptEmployees),
+			ToCreateEmployers:    len(toCudEmployer.ToCreate),
+			ToUpdateEmployers:    len(toCudEmployer.ToUpdate),
+			ToDeleteEmployers:    len(toCudEmployer.ToDelete),
+			ToCreateEmployees:    len(toCudEmployees.ToCreate),
+			ToUpdateEmployees:    len(toCudEmployees.ToUpdate),
+			ToDeleteEmployees:    len(toCudEmployees.ToDelete),
+		},
+	}
+	sample := func(ids []string) []string {
+		if len(ids) <= importReportSampleSize {
+			return ids
+		}
+		report.Truncated = true
+		return ids[:importReportSampleSize]
+	}
+	report.AdoptedEmployers = sample(employerIds(succAdoptEmployer))
+	report.FailAdoptedEmployers = sample(employerIds(failAdoptEmployer))
+	report.AdoptedEmployees = sample(employeeIds(succAdoptEmployees))
+	report.FailAdoptedEmployees = sample(employeeIds(failAdoptEmployees))
+	report.ToCreateEmployers = sample(employerIds(toCudEmployer.ToCreate))
+	report.ToUpdateEmployers = sample(employerIds(toCudEmployer.ToUpdate))
+	report.ToDeleteEmployers = sample(employerIds(toCudEmployer.ToDelete))
+	report.ToCreateEmployees = sample(employeeIds(toCudEmployees.ToCreate))
+	report.ToUpdateEmployees = sample(employeeIds(toCudEmployees.ToUpdate))
+	report.ToDeleteEmployees = sample(employeeIds(toCudEmployees.ToDelete))
+	return report
+}
+
+func employerIds(employers []IEmployer) []string {
+	ids := make([]string, len(employers))
+	for idx, employer := range employers {
+		ids[idx] = employer.GetEmployerId()
+	}
+	return ids
+}
+
+func employeeIds(employees []IEmployee) []string {
+	ids := make([]string, len(employees))
+	for idx, employee := range employees {
+		ids[idx] = employee.GetEmployeeId()
+	}
+	return ids
+}
+
+// idSample formats count of ids and at most importReportSampleSize of them, bounding size of events
+func idSample(ids []string) string {
+	if len(ids) <= importReportSampleSize {
+		return fmt.Sprintf("%d %v", len(ids), ids)
+	}
+	return fmt.Sprintf("%d %v(truncated)", len(ids), ids[:importReportSampleSize])
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type importAdapter struct {
+	*memoryAdapter
+}
+
+var _ ReconcileImportOptions = &importAdapter{}
+
+func (i *importAdapter) AdoptEmployer(ctx context.Context, employer client.Object, toAdopts []IEmployer) ([]IEmployer, []IEmployer, error) {
+	i.record("AdoptEmployer")
+	return toAdopts, nil, nil
+}
+
+func (i *importAdapter) AdoptEmployees(ctx context.Context, employer client.Object, toAdopts []IEmployee) ([]IEmployee, []IEmployee, error) {
+	i.record("AdoptEmployees")
+	return toAdopts, nil, nil
+}
+
+// flakyImportAdapter fails adopting failOnce employees at the first try, and records ids of each try
+type flakyImportAdapter struct {
+	*importAdapter
+	failOnce []string
+	adopted  [][]string
+}
+
+func (f *flakyImportAdapter) AdoptEmployees(ctx context.Context, employer client.Object, toAdopts []IEmployee) ([]IEmployee, []IEmployee, error) {
+	ids := employeeIds(toAdopts)
+	sort.Strings(ids)
+	f.adopted = append(f.adopted, ids)
+	var succ, fail []IEmployee
+	for _, employee := range toAdopts {
+		if contains(f.failOnce, employee.GetEmployeeId()) {
+			fail = append(fail, employee)
+		} else {
+			succ = append(succ, employee)
+		}
+	}
+	f.failOnce = nil
+	return succ, fail, nil
+}
+
+func writeCalls(calls []string) []string {
+	var writes []string
+	for _, call := range calls {
+		switch call {
+		case "CreateEmployer", "UpdateEmployer", "DeleteEmployer", "CreateEmployees", "UpdateEmployees", "DeleteEmployees":
+			writes = append(writes, call)
+		}
+	}
+	return writes
+}
+
+func TestImportAdoptAndConfirm(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryAdapter([]string{"svc"}, "pod-a", "pod-b")
+	memory.expectedEmployees = []string{"pod-a", "pod-c"}
+	adapter := &importAdapter{memoryAdapter: memory}
+	svc := newTestService("svc", nil)
+	r := newFakeConsist(adapter, []client.Object{svc})
+	latest := func() *corev1.Service {
+		s := &corev1.Service{}
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(svc), s))
+		return s
+	}
+
+	// clean finalizer added together with import mark
+	_, err := reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	assert.Equal(t, importStatePending, latest().Annotations[importStateAnnoKey()])
+
+	// adopted and waiting for confirmation, nothing written to backend provider
+	for i := 0; i < 2; i++ {
+		_, err = reconcileEmployer(t, r, svc)
+		require.NoError(t, err)
+	}
+	annos := latest().Annotations
+	assert.Equal(t, importStateAdopted, annos[importStateAnnoKey()])
+	var report ImportReport
+	require.NoError(t, json.Unmarshal([]byte(annos[importReportAnnoKey()]), &report))
+	assert.Equal(t, 1, report.Counts.AdoptedEmployers)
+	assert.Equal(t, 2, report.Counts.AdoptedEmployees)
+	assert.Equal(t, []string{"pod-c"}, report.ToCreateEmployees)
+	assert.Equal(t, []string{"pod-b"}, report.ToDeleteEmployees)
+	assert.False(t, report.Truncated)
+	assert.Empty(t, writeCalls(memory.getCalls()))
+	assert.Equal(t, []string{"pod-a", "pod-b"}, memory.employeeIds())
+
+	// converged once confirmed
+	confirmed := latest()
+	confirmed.Annotations[ImportConfirmedAnnoKey()] = "true"
+	require.NoError(t, r.Update(ctx, confirmed))
+	result, err := reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	assert.True(t, result.Requeue)
+	_, err = reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	annos = latest().Annotations
+	assert.NotContains(t, annos, importStateAnnoKey())
+	assert.NotContains(t, annos, importReportAnnoKey())
+	assert.Equal(t, []string{"CreateEmployees", "DeleteEmployees"}, writeCalls(memory.getCalls()))
+	assert.Equal(t, []string{"pod-a", "pod-c"}, memory.employeeIds())
+}
+
+func TestImportReportTruncated(t *testing.T) {
+	var current []IEmployee
+	for i := 0; i < 3*importReportSampleSize; i++ {
+		current = append(current, memoryEmployee(fmt.Sprintf("pod-%d", i)))
+	}
+	report := newImportReport(nil, nil, current, nil, ToCUDEmployer{}, ToCUDEmployees{ToDelete: current})
+	assert.True(t, report.Truncated)
+	assert.Equal(t, len(current), report.Counts.AdoptedEmployees)
+	assert.Equal(t, len(current), report.Counts.ToDeleteEmployees)
+	assert.Len(t, report.AdoptedEmployees, importReportSampleSize)
+	assert.Len(t, report.ToDeleteEmployees, importReportSampleSize)
+}
+
+func TestImportSkippedIfNothingToAdopt(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryAdapter(nil)
+	memory.expectedEmployees = []string{"pod-a"}
+	memory.followLifecycle = true
+	memory.selected = []string{"pod-a"}
+	svc := newTestService("svc", nil)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod-a"}}
+	r := newFakeConsist(&importAdapter{memoryAdapter: memory}, []client.Object{svc, pod})
+	latestPod := func() *corev1.Pod {
+		p := &corev1.Pod{}
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(pod), p))
+		return p
+	}
+
+	// expected finalizers not ensured while importing
+	_, err := reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	result, err := reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	assert.True(t, result.Requeue)
+	assert.Empty(t, latestPod().Annotations)
+	latest := &corev1.Service{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(svc), latest))
+	assert.NotContains(t, latest.Annotations, importStateAnnoKey())
+	assert.NotContains(t, memory.getCalls(), "AdoptEmployees")
+
+	_, err = reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CreateEmployer", "CreateEmployees"}, writeCalls(memory.getCalls()))
+	assert.NotEmpty(t, latestPod().Annotations)
+}
+
+func TestImportRetriesFailedAdoptsOnly(t *testing.T) {
+	ctx := context.Background()
+	memory := newMemoryAdapter([]string{"svc"}, "pod-a", "pod-b")
+	adapter := &flakyImportAdapter{importAdapter: &importAdapter{memoryAdapter: memory}, failOnce: []string{"pod-b"}}
+	svc := newTestService("svc", nil)
+	r := newFakeConsist(adapter, []client.Object{svc})
+
+	_, err := reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	_, err = reconcileEmployer(t, r, svc)
+	assert.Error(t, err)
+	_, err = reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"pod-a", "pod-b"}, {"pod-b"}}, adapter.adopted)
+
+	latest := &corev1.Service{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(svc), latest))
+	assert.Equal(t, importStateAdopted, latest.Annotations[importStateAnnoKey()])
+	var report ImportReport
+	require.NoError(t, json.Unmarshal([]byte(latest.Annotations[importReportAnnoKey()]), &report))
+	assert.Equal(t, 2, report.Counts.AdoptedEmployees)
+	assert.Equal(t, 0, report.Counts.FailAdoptedEmployees)
+}
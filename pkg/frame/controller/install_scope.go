@@ -0,0 +1,69 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import "kusionstack.io/resourceconsist/pkg/utils"
+
+// Annotation keys and condition types are prefixed with install name for non-default installs, so that installs
+// sharing employers leave those of each other alone, see utils.SetInstallName.
+
+func expectedFinalizerAddedAnnoKey() string {
+	return utils.InstallScopedKey(defaultExpectedFinalizerAddedAnnoKey)
+}
+
+func lifecycleFinalizerRecordedAnnoKey() string {
+	return utils.InstallScopedKey(defaultLifecycleFinalizerRecordedAnnoKey)
+}
+
+// DeletionPolicyAnnoKey returns the annotation on employer deciding what happens to backend resources when employer
+// deleted, DeletionPolicyDelete will be used if not set or set to an unknown value.
+func DeletionPolicyAnnoKey() string {
+	return utils.InstallScopedKey(defaultDeletionPolicyAnnoKey)
+}
+
+// ImportConfirmedAnnoKey returns the annotation on employer set to "true" by operators to confirm the import report,
+// normal convergence starts only after import confirmed. Only works for adapters implementing ReconcileImportOptions.
+func ImportConfirmedAnnoKey() string {
+	return utils.InstallScopedKey(defaultImportConfirmedAnnoKey)
+}
+
+// importStateAnnoKey records the import state of a newly managed employer, erased after import confirmed
+func importStateAnnoKey() string {
+	return utils.InstallScopedKey(defaultImportStateAnnoKey)
+}
+
+// importReportAnnoKey records what adopted and what will be done after import confirmed
+func importReportAnnoKey() string {
+	return utils.InstallScopedKey(defaultImportReportAnnoKey)
+}
+
+// DriftConditionType returns the condition type recorded to employer's status in observe mode
+func DriftConditionType() string {
+	return utils.InstallScopedKey(defaultDriftConditionType)
+}
+
+// DuplicateIdsConditionType returns the condition type recorded to Service employer's status if duplicated ids found
+// and StatusRecordOptions not implemented, erased once no duplicated ids
+func DuplicateIdsConditionType() string {
+	return utils.InstallScopedKey(defaultDuplicateIdsConditionType)
+}
+
+// TerminalErrorConditionType returns the condition type recorded to Service employer's status if reconcile failed
+// with ErrTerminal, erased once reconcile succeeded
+func TerminalErrorConditionType() string {
+	return utils.InstallScopedKey(defaultTerminalErrorConditionType)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+func TestInstallScopedKeys(t *testing.T) {
+	assert.Equal(t, "resource-consist.kusionstack.io/deletion-policy", DeletionPolicyAnnoKey())
+	assert.Equal(t, "ResourceConsistDrifted", DriftConditionType())
+
+	require.NoError(t, utils.SetInstallName("canary"))
+	defer func() {
+		_ = utils.SetInstallName("")
+	}()
+	assert.Equal(t, "canary.resource-consist.kusionstack.io/deletion-policy", DeletionPolicyAnnoKey())
+	assert.Equal(t, "canary.resource-consist.kusionstack.io/employees-expected-finalizer-added", expectedFinalizerAddedAnnoKey())
+	assert.Equal(t, "canary/ResourceConsistDrifted", DriftConditionType())
+	for _, key := range []string{DeletionPolicyAnnoKey(), importReportAnnoKey(), TerminalErrorConditionType()} {
+		assert.Empty(t, validation.IsQualifiedName(key), key)
+	}
+
+	// invalid names rejected, install name kept
+	for _, name := range []string{"Canary", "canary.v2", "canary/v2", "-canary"} {
+		assert.Error(t, utils.SetInstallName(name), name)
+	}
+	assert.Equal(t, "canary", utils.GetInstallName())
+}
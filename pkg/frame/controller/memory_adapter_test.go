@@ -0,0 +1,260 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// memoryAdapter keeps backend resources in memory and records adapter calls in order(writes with nothing to write
+// not recorded), employer is expected to own
+// resource of its name and employees of expectedEmployees until deleted
+type memoryAdapter struct {
+	mu                sync.Mutex
+	employers         map[string]IEmployer
+	employees         map[string]IEmployee
+	expectedEmployees []string
+	calls             []string
+
+	followLifecycle bool
+	selected        []string
+}
+
+var _ ReconcileAdapter = &memoryAdapter{}
+var _ ReconcileLifecycleOptions = &memoryAdapter{}
+
+func newMemoryAdapter(employerIds []string, employeeNames ...string) *memoryAdapter {
+	m := &memoryAdapter{
+		employers:         map[string]IEmployer{},
+		employees:         map[string]IEmployee{},
+		expectedEmployees: employeeNames,
+	}
+	for _, id := range employerIds {
+		m.employers[id] = memoryEmployer(id)
+	}
+	for _, name := range employeeNames {
+		m.employees[name] = memoryEmployee(name)
+	}
+	return m
+}
+
+func memoryEmployer(id string) IEmployer {
+	return &DemoServiceStatus{EmployerId: id, EmployerStatuses: DemoServiceDetails{RemoteVIP: id}}
+}
+
+func memoryEmployee(name string) IEmployee {
+	return &DemoPodStatus{EmployeeId: name, EmployeeName: name, EmployeeStatuses: PodEmployeeStatuses{Ip: name}}
+}
+
+func (m *memoryAdapter) record(call string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, call)
+}
+
+func (m *memoryAdapter) getCalls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.calls...)
+}
+
+func (m *memoryAdapter) employerIds() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id := range m.employers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *memoryAdapter) employeeIds() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id := range m.employees {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *memoryAdapter) GetControllerName() string {
+	return "memory-controller"
+}
+
+func (m *memoryAdapter) FollowPodOpsLifeCycle() bool {
+	return m.followLifecycle
+}
+
+func (m *memoryAdapter) NeedRecordLifecycleFinalizerCondition() bool {
+	return false
+}
+
+func (m *memoryAdapter) GetSelectedEmployeeNames(ctx context.Context, employer client.Object) ([]string, error) {
+	return m.selected, nil
+}
+
+func (m *memoryAdapter) GetExpectedEmployer(ctx context.Context, employer client.Object) ([]IEmployer, error) {
+	m.record("GetExpectedEmployer")
+	if !employer.GetDeletionTimestamp().IsZero() {
+		return nil, nil
+	}
+	return []IEmployer{memoryEmployer(employer.GetName())}, nil
+}
+
+func (m *memoryAdapter) GetCurrentEmployer(ctx context.Context, employer client.Object) ([]IEmployer, error) {
+	m.record("GetCurrentEmployer")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var current []IEmployer
+	for _, e := range m.employers {
+		current = append(current, e)
+	}
+	return current, nil
+}
+
+func (m *memoryAdapter) CreateEmployer(ctx context.Context, employer client.Object, toCreates []IEmployer) ([]IEmployer, []IEmployer, error) {
+	if len(toCreates) != 0 {
+		m.record("CreateEmployer")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range toCreates {
+		m.employers[e.GetEmployerId()] = e
+	}
+	return toCreates, nil, nil
+}
+
+func (m *memoryAdapter) UpdateEmployer(ctx context.Context, employer client.Object, toUpdates []IEmployer) ([]IEmployer, []IEmployer, error) {
+	if len(toUpdates) != 0 {
+		m.record("UpdateEmployer")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range toUpdates {
+		m.employers[e.GetEmployerId()] = e
+	}
+	return toUpdates, nil, nil
+}
+
+func (m *memoryAdapter) DeleteEmployer(ctx context.Context, employer client.Object, toDeletes []IEmployer) ([]IEmployer, []IEmployer, error) {
+	if len(toDeletes) != 0 {
+		m.record("DeleteEmployer")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range toDeletes {
+		delete(m.employers, e.GetEmployerId())
+	}
+	return toDeletes, nil, nil
+}
+
+func (m *memoryAdapter) GetExpectedEmployee(ctx context.Context, employer client.Object) ([]IEmployee, error) {
+	m.record("GetExpectedEmployee")
+	if !employer.GetDeletionTimestamp().IsZero() {
+		return nil, nil
+	}
+	var expected []IEmployee
+	for _, name := range m.expectedEmployees {
+		expected = append(expected, memoryEmployee(name))
+	}
+	return expected, nil
+}
+
+func (m *memoryAdapter) GetCurrentEmployee(ctx context.Context, employer client.Object) ([]IEmployee, error) {
+	m.record("GetCurrentEmployee")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var current []IEmployee
+	for _, e := range m.employees {
+		current = append(current, e)
+	}
+	return current, nil
+}
+
+func (m *memoryAdapter) CreateEmployees(ctx context.Context, employer client.Object, toCreates []IEmployee) ([]IEmployee, []IEmployee, error) {
+	if len(toCreates) != 0 {
+		m.record("CreateEmployees")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range toCreates {
+		m.employees[e.GetEmployeeId()] = e
+	}
+	return toCreates, nil, nil
+}
+
+func (m *memoryAdapter) UpdateEmployees(ctx context.Context, employer client.Object, toUpdates []IEmployee) ([]IEmployee, []IEmployee, error) {
+	if len(toUpdates) != 0 {
+		m.record("UpdateEmployees")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range toUpdates {
+		m.employees[e.GetEmployeeId()] = e
+	}
+	return toUpdates, nil, nil
+}
+
+func (m *memoryAdapter) DeleteEmployees(ctx context.Context, employer client.Object, toDeletes []IEmployee) ([]IEmployee, []IEmployee, error) {
+	if len(toDeletes) != 0 {
+		m.record("DeleteEmployees")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range toDeletes {
+		delete(m.employees, e.GetEmployeeId())
+	}
+	return toDeletes, nil, nil
+}
+
+// newFakeConsist returns Consist running against a fake client with objs, events are dropped
+func newFakeConsist(adapter ReconcileAdapter, objs []client.Object, opts ...Option) *Consist {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objs...).Build()
+	return newConsist(c, clientgoscheme.Scheme, &record.FakeRecorder{}, adapter, opts...)
+}
+
+func reconcileEmployer(t *testing.T, r *Consist, employer client.Object) (reconcile.Result, error) {
+	t.Helper()
+	return r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{
+		Namespace: employer.GetNamespace(),
+		Name:      employer.GetName(),
+	}})
+}
+
+func newTestService(name string, annotations map[string]string, finalizers ...string) *corev1.Service {
+	return &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        name,
+		Annotations: annotations,
+		Finalizers:  finalizers,
+	}}
+}
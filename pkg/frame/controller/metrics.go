@@ -0,0 +1,73 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "resourceconsist"
+
+var (
+	// observedDrift records count of resources to be created/updated/deleted observed in observe mode
+	observedDrift = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "observed_drift",
+		Help:      "Count of resources drifted from expected, observed in observe mode, by employer/employee and action.",
+	}, []string{"controller", "namespace", "name", "resource", "action"})
+
+	// garbageCollected records count of missing employers whose resources collected by garbage collector
+	garbageCollected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "garbage_collected_employers_total",
+		Help:      "Count of missing employers whose resources on backend provider collected.",
+	}, []string{"controller", "dry_run"})
+
+	// circuitBreakerState records whether circuit breaker of CallGovernor is open, 1 for open and 0 for closed
+	circuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "provider_circuit_breaker_open",
+		Help:      "Whether circuit breaker of backend provider calls is open.",
+	}, []string{"governor"})
+
+	// circuitBreakerOpened records count of circuit breaker of CallGovernor opened
+	circuitBreakerOpened = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "provider_circuit_breaker_opened_total",
+		Help:      "Count of circuit breaker of backend provider calls opened.",
+	}, []string{"governor"})
+
+	// adapterCallTimeouts records count of calls to adapter exceeding deadline
+	adapterCallTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "adapter_call_timeouts_total",
+		Help:      "Count of calls to adapter exceeding deadline, by method.",
+	}, []string{"controller", "method"})
+
+	// invalidAdapterResults records count of items returned by CUD methods of adapter violating the partition of requested
+	invalidAdapterResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "invalid_adapter_results_total",
+		Help:      "Count of items returned by CUD methods of adapter violating the partition of requested, by violation.",
+	}, []string{"controller", "method", "violation"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(observedDrift, garbageCollected, circuitBreakerState, circuitBreakerOpened,
+		adapterCallTimeouts, invalidAdapterResults)
+}
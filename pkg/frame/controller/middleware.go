@@ -0,0 +1,304 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AdapterMiddleware wraps calls to ReconcileAdapter with cross-cutting behaviour like logging, retry and fault
+// injection. Optional interfaces are always asserted on the adapter registered, so middlewares only need to wrap
+// methods of ReconcileAdapter.
+type AdapterMiddleware func(next ReconcileAdapter) ReconcileAdapter
+
+// WithAdapterMiddlewares registers middlewares around calls to adapter, the first one is the outermost.
+// Middlewares are called outside of the CallGovernor and ReconcileTimeoutOptions, so each retry is governed and bounded.
+func WithAdapterMiddlewares(middlewares ...AdapterMiddleware) Option {
+	return func(r *Consist) {
+		r.middlewares = append(r.middlewares, middlewares...)
+	}
+}
+
+// AdapterInterceptor intercepts one call to the method of ReconcileAdapter, named like "GetCurrentEmployee",
+// call invokes the next adapter and can be invoked more than once.
+type AdapterInterceptor func(ctx context.Context, method string, employer client.Object, call func(ctx context.Context) error) error
+
+// NewInterceptorMiddleware creates an AdapterMiddleware calling interceptor around each call to ReconcileAdapter
+func NewInterceptorMiddleware(interceptor AdapterInterceptor) AdapterMiddleware {
+	return func(next ReconcileAdapter) ReconcileAdapter {
+		return &interceptedAdapter{ReconcileAdapter: next, intercept: interceptor}
+	}
+}
+
+// LoggingMiddleware logs each call to ReconcileAdapter with its duration and error
+func LoggingMiddleware(logger logr.Logger) AdapterMiddleware {
+	return NewInterceptorMiddleware(func(ctx context.Context, method string, employer client.Object, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		keysAndValues := []interface{}{"method", method, "employer", employer.GetNamespace() + "/" + employer.GetName(),
+			"duration", time.Since(start)}
+		if err != nil {
+			logger.Error(err, "adapter call failed", keysAndValues...)
+			return err
+		}
+		logger.Info("adapter call succeeded", keysAndValues...)
+		return nil
+	})
+}
+
+// GetRetryMiddleware retries failed calls to Get* methods of ReconcileAdapter with backoff, ErrTerminal,
+// CircuitOpenError and errors of ctx are not retried. CUD methods are never retried since they might be partially done.
+func GetRetryMiddleware(backoff wait.Backoff) AdapterMiddleware {
+	return NewInterceptorMiddleware(func(ctx context.Context, method string, employer client.Object, call func(ctx context.Context) error) error {
+		if !strings.HasPrefix(method, "Get") {
+			return call(ctx)
+		}
+		return retry.OnError(backoff, func(err error) bool {
+			var terminal *ErrTerminal
+			var circuitOpen *CircuitOpenError
+			return ctx.Err() == nil && !errors.As(err, &terminal) && !errors.As(err, &circuitOpen)
+		}, func() error {
+			return call(ctx)
+		})
+	})
+}
+
+// ValidationMiddleware corrects results of ReconcileAdapter before they reach outer middlewares: nil items or items
+// with empty id returned by Get* methods are dropped, and succ/fail returned by CUD methods are made to partition the
+// requested as the controller does, see InvalidAdapterResults. Items corrected are logged and counted in metrics.
+func ValidationMiddleware(logger logr.Logger) AdapterMiddleware {
+	return func(next ReconcileAdapter) ReconcileAdapter {
+		return &validatedAdapter{ReconcileAdapter: next, logger: logger}
+	}
+}
+
+type interceptedAdapter struct {
+	ReconcileAdapter
+	intercept AdapterInterceptor
+}
+
+func (a *interceptedAdapter) GetExpectedEmployer(ctx context.Context, employer client.Object) (expected []IEmployer, err error) {
+	err = a.intercept(ctx, "GetExpectedEmployer", employer, func(ctx context.Context) error {
+		expected, err = a.ReconcileAdapter.GetExpectedEmployer(ctx, employer)
+		return err
+	})
+	return expected, err
+}
+
+func (a *interceptedAdapter) GetCurrentEmployer(ctx context.Context, employer client.Object) (current []IEmployer, err error) {
+	err = a.intercept(ctx, "GetCurrentEmployer", employer, func(ctx context.Context) error {
+		current, err = a.ReconcileAdapter.GetCurrentEmployer(ctx, employer)
+		return err
+	})
+	return current, err
+}
+
+func (a *interceptedAdapter) CreateEmployer(ctx context.Context, employer client.Object, toCreates []IEmployer) (succ, fail []IEmployer, err error) {
+	err = a.intercept(ctx, "CreateEmployer", employer, func(ctx context.Context) error {
+		succ, fail, err = a.ReconcileAdapter.CreateEmployer(ctx, employer, toCreates)
+		return err
+	})
+	return succ, fail, err
+}
+
+func (a *interceptedAdapter) UpdateEmployer(ctx context.Context, employer client.Object, toUpdates []IEmployer) (succ, fail []IEmployer, err error) {
+	err = a.intercept(ctx, "UpdateEmployer", employer, func(ctx context.Context) error {
+		succ, fail, err = a.ReconcileAdapter.UpdateEmployer(ctx, employer, toUpdates)
+		return err
+	})
+	return succ, fail, err
+}
+
+func (a *interceptedAdapter) DeleteEmployer(ctx context.Context, employer client.Object, toDeletes []IEmployer) (succ, fail []IEmployer, err error) {
+	err = a.intercept(ctx, "DeleteEmployer", employer, func(ctx context.Context) error {
+		succ, fail, err = a.ReconcileAdapter.DeleteEmployer(ctx, employer, toDeletes)
+		return err
+	})
+	return succ, fail, err
+}
+
+func (a *interceptedAdapter) GetExpectedEmployee(ctx context.Context, employer client.Object) (expected []IEmployee, err error) {
+	err = a.intercept(ctx, "GetExpectedEmployee", employer, func(ctx context.Context) error {
+		expected, err = a.ReconcileAdapter.GetExpectedEmployee(ctx, employer)
+		return err
+	})
+	return expected, err
+}
+
+func (a *interceptedAdapter) GetCurrentEmployee(ctx context.Context, employer client.Object) (current []IEmployee, err error) {
+	err = a.intercept(ctx, "GetCurrentEmployee", employer, func(ctx context.Context) error {
+		current, err = a.ReconcileAdapter.GetCurrentEmployee(ctx, employer)
+		return err
+	})
+	return current, err
+}
+
+func (a *interceptedAdapter) CreateEmployees(ctx context.Context, employer client.Object, toCreates []IEmployee) (succ, fail []IEmployee, err error) {
+	err = a.intercept(ctx, "CreateEmployees", employer, func(ctx context.Context) error {
+		succ, fail, err = a.ReconcileAdapter.CreateEmployees(ctx, employer, toCreates)
+		return err
+	})
+	return succ, fail, err
+}
+
+func (a *interceptedAdapter) UpdateEmployees(ctx context.Context, employer client.Object, toUpdates []IEmployee) (succ, fail []IEmployee, err error) {
+	err = a.intercept(ctx, "UpdateEmployees", employer, func(ctx context.Context) error {
+		succ, fail, err = a.ReconcileAdapter.UpdateEmployees(ctx, employer, toUpdates)
+		return err
+	})
+	return succ, fail, err
+}
+
+func (a *interceptedAdapter) DeleteEmployees(ctx context.Context, employer client.Object, toDeletes []IEmployee) (succ, fail []IEmployee, err error) {
+	err = a.intercept(ctx, "DeleteEmployees", employer, func(ctx context.Context) error {
+		succ, fail, err = a.ReconcileAdapter.DeleteEmployees(ctx, employer, toDeletes)
+		return err
+	})
+	return succ, fail, err
+}
+
+type validatedAdapter struct {
+	ReconcileAdapter
+	logger logr.Logger
+}
+
+func (a *validatedAdapter) reportInvalidItems(employer client.Object, method string, dropped int) {
+	if dropped == 0 {
+		return
+	}
+	invalidAdapterResults.WithLabelValues(a.GetControllerName(), method, "unknown").Add(float64(dropped))
+	a.logger.Info("adapter returned items nil or with empty id, dropped", "method", method,
+		"employer", employer.GetNamespace()+"/"+employer.GetName(), "count", dropped)
+}
+
+func (a *validatedAdapter) reportViolations(employer client.Object, method string, violations partitionViolations) {
+	if !violations.violated() {
+		return
+	}
+	countPartitionViolations(a.GetControllerName(), method, violations)
+	a.logger.Info("succ/fail returned by adapter don't partition requested, corrected", "method", method,
+		"employer", employer.GetNamespace()+"/"+employer.GetName(), "unknown", violations.Unknown,
+		"duplicated", violations.Duplicated, "unaccounted", violations.Unaccounted)
+}
+
+func (a *validatedAdapter) GetExpectedEmployer(ctx context.Context, employer client.Object) ([]IEmployer, error) {
+	expected, err := a.ReconcileAdapter.GetExpectedEmployer(ctx, employer)
+	if err != nil {
+		return expected, err
+	}
+	expected, dropped := dropInvalidItems(expected, employerIdOf)
+	a.reportInvalidItems(employer, "GetExpectedEmployer", dropped)
+	return expected, nil
+}
+
+func (a *validatedAdapter) GetCurrentEmployer(ctx context.Context, employer client.Object) ([]IEmployer, error) {
+	current, err := a.ReconcileAdapter.GetCurrentEmployer(ctx, employer)
+	if err != nil {
+		return current, err
+	}
+	current, dropped := dropInvalidItems(current, employerIdOf)
+	a.reportInvalidItems(employer, "GetCurrentEmployer", dropped)
+	return current, nil
+}
+
+func (a *validatedAdapter) CreateEmployer(ctx context.Context, employer client.Object, toCreates []IEmployer) ([]IEmployer, []IEmployer, error) {
+	succ, fail, err := a.ReconcileAdapter.CreateEmployer(ctx, employer, toCreates)
+	if err != nil {
+		return succ, fail, err
+	}
+	succ, fail, violations := partitionResults(toCreates, succ, fail, employerIdOf)
+	a.reportViolations(employer, "CreateEmployer", violations)
+	return succ, fail, nil
+}
+
+func (a *validatedAdapter) UpdateEmployer(ctx context.Context, employer client.Object, toUpdates []IEmployer) ([]IEmployer, []IEmployer, error) {
+	succ, fail, err := a.ReconcileAdapter.UpdateEmployer(ctx, employer, toUpdates)
+	if err != nil {
+		return succ, fail, err
+	}
+	succ, fail, violations := partitionResults(toUpdates, succ, fail, employerIdOf)
+	a.reportViolations(employer, "UpdateEmployer", violations)
+	return succ, fail, nil
+}
+
+func (a *validatedAdapter) DeleteEmployer(ctx context.Context, employer client.Object, toDeletes []IEmployer) ([]IEmployer, []IEmployer, error) {
+	succ, fail, err := a.ReconcileAdapter.DeleteEmployer(ctx, employer, toDeletes)
+	if err != nil {
+		return succ, fail, err
+	}
+	succ, fail, violations := partitionResults(toDeletes, succ, fail, employerIdOf)
+	a.reportViolations(employer, "DeleteEmployer", violations)
+	return succ, fail, nil
+}
+
+func (a *validatedAdapter) GetExpectedEmployee(ctx context.Context, employer client.Object) ([]IEmployee, error) {
+	expected, err := a.ReconcileAdapter.GetExpectedEmployee(ctx, employer)
+	if err != nil {
+		return expected, err
+	}
+	expected, dropped := dropInvalidItems(expected, employeeIdOf)
+	a.reportInvalidItems(employer, "GetExpectedEmployee", dropped)
+	return expected, nil
+}
+
+func (a *validatedAdapter) GetCurrentEmployee(ctx context.Context, employer client.Object) ([]IEmployee, error) {
+	current, err := a.ReconcileAdapter.GetCurrentEmployee(ctx, employer)
+	if err != nil {
+		return current, err
+	}
+	current, dropped := dropInvalidItems(current, employeeIdOf)
+	a.reportInvalidItems(employer, "GetCurrentEmployee", dropped)
+	return current, nil
+}
+
+func (a *validatedAdapter) CreateEmployees(ctx context.Context, employer client.Object, toCreates []IEmployee) ([]IEmployee, []IEmployee, error) {
+	succ, fail, err := a.ReconcileAdapter.CreateEmployees(ctx, employer, toCreates)
+	if err != nil {
+		return succ, fail, err
+	}
+	succ, fail, violations := partitionResults(toCreates, succ, fail, employeeIdOf)
+	a.reportViolations(employer, "CreateEmployees", violations)
+	return succ, fail, nil
+}
+
+func (a *validatedAdapter) UpdateEmployees(ctx context.Context, employer client.Object, toUpdates []IEmployee) ([]IEmployee, []IEmployee, error) {
+	succ, fail, err := a.ReconcileAdapter.UpdateEmployees(ctx, employer, toUpdates)
+	if err != nil {
+		return succ, fail, err
+	}
+	succ, fail, violations := partitionResults(toUpdates, succ, fail, employeeIdOf)
+	a.reportViolations(employer, "UpdateEmployees", violations)
+	return succ, fail, nil
+}
+
+func (a *validatedAdapter) DeleteEmployees(ctx context.Context, employer client.Object, toDeletes []IEmployee) ([]IEmployee, []IEmployee, error) {
+	succ, fail, err := a.ReconcileAdapter.DeleteEmployees(ctx, employer, toDeletes)
+	if err != nil {
+		return succ, fail, err
+	}
+	succ, fail, violations := partitionResults(toDeletes, succ, fail, employeeIdOf)
+	a.reportViolations(employer, "DeleteEmployees", violations)
+	return succ, fail, nil
+}
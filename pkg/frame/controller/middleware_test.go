@@ -0,0 +1,92 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type flakyAdapter struct {
+	DemoControllerAdapter
+	failures int
+	calls    int
+	current  []IEmployee
+	// err is returned by failed calls, "flaky" if nil
+	err error
+}
+
+func (f *flakyAdapter) GetCurrentEmployee(ctx context.Context, employer client.Object) ([]IEmployee, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		if f.err != nil {
+			return nil, f.err
+		}
+		return nil, fmt.Errorf("flaky")
+	}
+	return f.current, nil
+}
+
+func (f *flakyAdapter) DeleteEmployees(ctx context.Context, employer client.Object, toDeletes []IEmployee) ([]IEmployee, []IEmployee, error) {
+	return f.current, nil, nil
+}
+
+func TestAdapterMiddlewares(t *testing.T) {
+	employer := &corev1.Service{}
+	flaky := &flakyAdapter{failures: 2, current: []IEmployee{&DemoPodStatus{EmployeeId: "a"}}}
+	backoff := wait.Backoff{Steps: 3}
+
+	adapter := GetRetryMiddleware(backoff)(flaky)
+	current, err := adapter.GetCurrentEmployee(context.Background(), employer)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, flaky.calls)
+	assert.Len(t, current, 1)
+
+	// terminal errors and open circuit breaker are not retried
+	for _, failure := range []error{&ErrTerminal{Err: fmt.Errorf("invalid")}, &CircuitOpenError{RetryAfter: time.Second}} {
+		flaky.calls, flaky.failures, flaky.err = 0, 3, failure
+		_, err = adapter.GetCurrentEmployee(context.Background(), employer)
+		assert.Equal(t, failure, err)
+		assert.Equal(t, 1, flaky.calls)
+	}
+}
+
+func TestValidationMiddleware(t *testing.T) {
+	employer := &corev1.Service{}
+	flaky := &flakyAdapter{current: []IEmployee{&DemoPodStatus{EmployeeId: "a"}, nil, &DemoPodStatus{}}}
+	adapter := LoggingMiddleware(logr.Discard())(ValidationMiddleware(logr.Discard())(flaky))
+
+	// nil items and items with empty id dropped
+	current, err := adapter.GetCurrentEmployee(context.Background(), employer)
+	assert.NoError(t, err)
+	assert.Equal(t, []IEmployee{&DemoPodStatus{EmployeeId: "a"}}, current)
+
+	// deleted employee not requested dropped, employee requested but not returned failed
+	requested := []IEmployee{&DemoPodStatus{EmployeeId: "b"}}
+	succ, fail, err := adapter.DeleteEmployees(context.Background(), employer, requested)
+	assert.NoError(t, err)
+	assert.Empty(t, succ)
+	assert.Equal(t, requested, fail)
+}
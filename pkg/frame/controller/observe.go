@@ -0,0 +1,128 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/kube-utils/multicluster/clusterinfo"
+)
+
+// observe is the Reconcile in observe mode, only GetExpected*/GetCurrent* of adapter called, and drift published via
+// metrics and DriftRecordOptions(or condition of Service if not implemented).
+func (r *Consist) observe(ctx context.Context, employer client.Object) error {
+	expectedEmployer, err := r.provider.GetExpectedEmployer(ctx, employer)
+	if err != nil {
+		return fmt.Errorf("get expect employer failed, err: %w", err)
+	}
+	currentEmployer, err := r.provider.GetCurrentEmployer(ctx, employer)
+	if err != nil {
+		return fmt.Errorf("get current employer failed, err: %w", err)
+	}
+	expectedEmployees, err := r.provider.GetExpectedEmployee(ctx, employer)
+	if err != nil {
+		return fmt.Errorf("get expect employees failed, err: %w", err)
+	}
+	currentEmployees, err := r.provider.GetCurrentEmployee(ctx, employer)
+	if err != nil {
+		return fmt.Errorf("get current employees failed, err: %w", err)
+	}
+	toCudEmployer, err := r.diffEmployer(employer, expectedEmployer, currentEmployer)
+	if err != nil {
+		return fmt.Errorf("diff employer failed, err: %w", err)
+	}
+	toCudEmployees, err := r.diffEmployees(employer, expectedEmployees, currentEmployees)
+	if err != nil {
+		return fmt.Errorf("diff employees failed, err: %w", err)
+	}
+
+	drift := Drift{
+		ToCreateEmployers: employerIds(toCudEmployer.ToCreate),
+		ToUpdateEmployers: employerIds(toCudEmployer.ToUpdate),
+		ToDeleteEmployers: employerIds(toCudEmployer.ToDelete),
+		ToCreateEmployees: employeeIds(toCudEmployees.ToCreate),
+		ToUpdateEmployees: employeeIds(toCudEmployees.ToUpdate),
+		ToDeleteEmployees: employeeIds(toCudEmployees.ToDelete),
+	}
+
+	controllerName := r.adapter.GetControllerName()
+	ns, name := employer.GetNamespace(), employer.GetName()
+	observedDrift.WithLabelValues(controllerName, ns, name, "employer", "create").Set(float64(len(drift.ToCreateEmployers)))
+	observedDrift.WithLabelValues(controllerName, ns, name, "employer", "update").Set(float64(len(drift.ToUpdateEmployers)))
+	observedDrift.WithLabelValues(controllerName, ns, name, "employer", "delete").Set(float64(len(drift.ToDeleteEmployers)))
+	observedDrift.WithLabelValues(controllerName, ns, name, "employee", "create").Set(float64(len(drift.ToCreateEmployees)))
+	observedDrift.WithLabelValues(controllerName, ns, name, "employee", "update").Set(float64(len(drift.ToUpdateEmployees)))
+	observedDrift.WithLabelValues(controllerName, ns, name, "employee", "delete").Set(float64(len(drift.ToDeleteEmployees)))
+
+	if driftRecordOptions, ok := r.adapter.(DriftRecordOptions); ok {
+		return driftRecordOptions.RecordDrift(ctx, employer, drift)
+	}
+	if svc, ok := employer.(*corev1.Service); ok {
+		return r.recordServiceDriftCondition(ctx, svc, drift)
+	}
+	return nil
+}
+
+func (r *Consist) recordServiceDriftCondition(ctx context.Context, svc *corev1.Service, drift Drift) error {
+	condition := metav1.Condition{
+		Type:               DriftConditionType(),
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: svc.Generation,
+		Reason:             "NoDrift",
+		Message:            "expected and current are consistent",
+	}
+	if drift.Drifted() {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "DriftDetected"
+		condition.Message = fmt.Sprintf("employer to create/update/delete: %d/%d/%d, employees to create/update/delete: %d/%d/%d",
+			len(drift.ToCreateEmployers), len(drift.ToUpdateEmployers), len(drift.ToDeleteEmployers),
+			len(drift.ToCreateEmployees), len(drift.ToUpdateEmployees), len(drift.ToDeleteEmployees))
+	}
+
+	svcOld := svc.DeepCopy()
+	meta.SetStatusCondition(&svc.Status.Conditions, condition)
+	return r.patchServiceConditions(ctx, svcOld, svc)
+}
+
+// patchServiceConditions patches conditions of svc if changed
+func (r *Consist) patchServiceConditions(ctx context.Context, svcOld, svc *corev1.Service) error {
+	if equality.Semantic.DeepEqual(svcOld.Status.Conditions, svc.Status.Conditions) {
+		return nil
+	}
+	patch := client.MergeFrom(svcOld)
+	if _, ok := r.adapter.(MultiClusterOptions); ok {
+		return r.Client.Status().Patch(clusterinfo.WithCluster(ctx, clusterinfo.Fed), svc, patch)
+	}
+	return r.Client.Status().Patch(ctx, svc, patch)
+}
+
+// forgetDrift deletes drift metrics of employer not found
+func (r *Consist) forgetDrift(namespace, name string) {
+	controllerName := r.adapter.GetControllerName()
+	for _, resource := range []string{"employer", "employee"} {
+		for _, action := range []string{"create", "update", "delete"} {
+			observedDrift.DeleteLabelValues(controllerName, namespace, name, resource, action)
+		}
+	}
+}
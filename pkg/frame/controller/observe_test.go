@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestObserveOnly(t *testing.T) {
+	ctx := context.Background()
+	adapter := newMemoryAdapter(nil, "pod-a")
+	adapter.expectedEmployees = []string{"pod-a", "pod-b"}
+	adapter.followLifecycle = true
+	adapter.selected = []string{"pod-a", "pod-b"}
+	svc := newTestService("svc", nil)
+	objs := []client.Object{svc}
+	for _, name := range adapter.selected {
+		objs = append(objs, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}})
+	}
+	r := newFakeConsist(adapter, objs, WithObserveOnly())
+
+	for i := 0; i < 2; i++ {
+		_, err := reconcileEmployer(t, r, svc)
+		require.NoError(t, err)
+	}
+
+	assert.Empty(t, writeCalls(adapter.getCalls()))
+	latest := &corev1.Service{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(svc), latest))
+	assert.Empty(t, latest.Finalizers)
+	assert.Empty(t, latest.Annotations)
+	drift := meta.FindStatusCondition(latest.Status.Conditions, DriftConditionType())
+	require.NotNil(t, drift)
+	assert.Equal(t, metav1.ConditionTrue, drift.Status)
+	assert.Equal(t, "employer to create/update/delete: 1/0/0, employees to create/update/delete: 1/0/0", drift.Message)
+
+	for _, name := range adapter.selected {
+		pod := &corev1.Pod{}
+		require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, pod))
+		assert.Empty(t, pod.Finalizers)
+		assert.Empty(t, pod.Annotations)
+	}
+}
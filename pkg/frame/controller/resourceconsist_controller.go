@@ -19,6 +19,8 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
@@ -40,10 +42,25 @@ import (
 	"kusionstack.io/kube-utils/multicluster/clusterinfo"
 )
 
+// Option configures the reconciler created by AddToMgr/NewReconcile
+type Option func(r *Consist)
+
+// WithObserveOnly runs the adapter in observe mode, only GetExpected*/GetCurrent* of adapter will be called, and the
+// drift between expected and current is published per employer via metrics and condition, see observe for details.
+// Nothing is mutated in observe mode, neither resources on backend provider nor finalizers/annos of employer and employees,
+// so it's useful for validating a new adapter against real traffic before it takes control.
+// Webhook adapters of the controller should implement webhook.ObserveOnlyAdapter too, so that expected finalizers are
+// not injected into employees.
+func WithObserveOnly() Option {
+	return func(r *Consist) {
+		r.observeOnly = true
+	}
+}
+
 // AddToMgr creates a new Controller of specified reconcileAdapter and adds it to the Manager with default RBAC.
 // The Manager will set fields on the Controller and Start it when the Manager is Started.
-func AddToMgr(mgr manager.Manager, adapter ReconcileAdapter) error {
-	r := NewReconcile(mgr, adapter)
+func AddToMgr(mgr manager.Manager, adapter ReconcileAdapter, opts ...Option) error {
+	r := NewReconcile(mgr, adapter, opts...)
 
 	// CreateEmployees a new controller
 	maxConcurrentReconciles := defaultMaxConcurrentReconciles
@@ -60,6 +77,26 @@ func AddToMgr(mgr manager.Manager, adapter ReconcileAdapter) error {
 		return err
 	}
 
+	if r.gcOptions != nil && !r.observeOnly {
+		gc, err := newGarbageCollector(r)
+		if err != nil {
+			return err
+		}
+		if err = mgr.Add(gc); err != nil {
+			return err
+		}
+	}
+
+	if r.finalizerSweepOptions != nil && !r.observeOnly {
+		sweeper, err := newFinalizerSweeper(r)
+		if err != nil {
+			return err
+		}
+		if err = mgr.Add(sweeper); err != nil {
+			return err
+		}
+	}
+
 	return watch(c, mgr, adapter)
 }
 
@@ -107,15 +144,34 @@ func watch(c controller.Controller, mgr manager.Manager, adapter ReconcileAdapte
 	return c.Watch(employeeSource, employeeEventHandler, employeePredicateFuncs)
 }
 
-func NewReconcile(mgr manager.Manager, reconcileAdapter ReconcileAdapter) *Consist {
-	recorder := mgr.GetEventRecorderFor(reconcileAdapter.GetControllerName())
-	return &Consist{
-		Client:   mgr.GetClient(),
-		scheme:   mgr.GetScheme(),
+func NewReconcile(mgr manager.Manager, reconcileAdapter ReconcileAdapter, opts ...Option) *Consist {
+	return newConsist(mgr.GetClient(), mgr.GetScheme(), mgr.GetEventRecorderFor(reconcileAdapter.GetControllerName()),
+		reconcileAdapter, opts...)
+}
+
+func newConsist(c client.Client, scheme *runtime.Scheme, recorder record.EventRecorder, reconcileAdapter ReconcileAdapter,
+	opts ...Option) *Consist {
+	r := &Consist{
+		Client:   c,
+		scheme:   scheme,
 		adapter:  reconcileAdapter,
 		logger:   logf.Log.WithName(reconcileAdapter.GetControllerName()).V(4),
 		recorder: recorder,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.provider = reconcileAdapter
+	if timeoutOptions, ok := reconcileAdapter.(ReconcileTimeoutOptions); ok {
+		r.provider = &timedAdapter{ReconcileAdapter: r.provider, timeoutOptions: timeoutOptions, recorder: recorder}
+	}
+	if r.governor != nil {
+		r.provider = &governedAdapter{ReconcileAdapter: r.provider, governor: r.governor}
+	}
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		r.provider = r.middlewares[i](r.provider)
+	}
+	return r
 }
 
 type Consist struct {
@@ -124,9 +180,36 @@ type Consist struct {
 	logger   logr.Logger
 	recorder record.EventRecorder
 	adapter  ReconcileAdapter
+	// provider is the adapter called for resources, bounded by ReconcileTimeoutOptions if implemented, governed by
+	// governor if set and wrapped by middlewares
+	provider    ReconcileAdapter
+	governor    *CallGovernor
+	middlewares []AdapterMiddleware
+
+	duplicateIdPolicy DuplicateIdPolicy
+
+	observeOnly bool
+	gcOptions   *GCOptions
+
+	finalizerSweepOptions *FinalizerSweepOptions
+
+	// unmanagedReported records digest of resources in UnmanagedResourcesSkipped last emitted per employer
+	unmanagedReported sync.Map
+	// importAdopted records ids adopted per importing employer, see importAdoptedIds
+	importAdopted sync.Map
 }
 
+// Reconcile requeues employer according to the type of error returned, see ErrThrottled/ErrTerminal/ErrConflict/ErrNotFound
 func (r *Consist) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	result, err := r.doReconcile(ctx, request)
+	return resultOfError(result, err)
+}
+
+func (r *Consist) doReconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	if timeoutOptions, ok := r.adapter.(ReconcileTimeoutOptions); ok {
+		ctx = withReconcileBudget(ctx, timeoutOptions.GetReconcileTimeout())
+	}
+
 	var employer client.Object
 	var err error
 
@@ -153,26 +236,49 @@ func (r *Consist) Reconcile(ctx context.Context, request reconcile.Request) (rec
 
 	if err != nil {
 		if errors.IsNotFound(err) {
+			if r.observeOnly {
+				r.forgetDrift(request.Namespace, request.Name)
+			}
+			r.forgetUnmanagedSkipped(request.Namespace, request.Name)
+			r.importAdopted.Delete(types.NamespacedName{Namespace: request.Namespace, Name: request.Name})
 			return reconcile.Result{}, nil
 		}
 		logger.Error(err, "get employer failed")
 		return reconcile.Result{}, err
 	}
 
-	defer func() {
+	if r.observeOnly {
+		err = r.observe(ctx, employer)
 		if err != nil {
-			if recordOptions, ok := r.adapter.(StatusRecordOptions); ok {
-				err = recordOptions.RecordErrorConditions(ctx, employer, err)
-				if err != nil {
-					logger.Error(err, "record error conditions failed")
-					r.recorder.Eventf(employer, corev1.EventTypeWarning, RecordErrorConditionsFailed,
-						"record error conditions failed: %s", err.Error())
-				}
+			logger.Error(err, "observe drift failed")
+			r.recorder.Eventf(employer, corev1.EventTypeWarning, ObserveDriftFailed,
+				"observe drift failed: %s", err.Error())
+		}
+		return reconcile.Result{}, err
+	}
+
+	defer func() {
+		recordOptions, recordOptionsImplemented := r.adapter.(StatusRecordOptions)
+		if err != nil && recordOptionsImplemented {
+			err = recordOptions.RecordErrorConditions(ctx, employer, classifyError(err))
+			if err != nil {
+				logger.Error(err, "record error conditions failed")
+				r.recorder.Eventf(employer, corev1.EventTypeWarning, RecordErrorConditionsFailed,
+					"record error conditions failed: %s", err.Error())
+			}
+			return
+		}
+		if svc, ok := employer.(*corev1.Service); ok && !recordOptionsImplemented {
+			if recordErr := r.recordServiceTerminalCondition(ctx, svc, err); recordErr != nil {
+				logger.Error(recordErr, "record terminal error condition failed")
+				r.recorder.Eventf(employer, corev1.EventTypeWarning, RecordErrorConditionsFailed,
+					"record terminal error condition failed: %s", recordErr.Error())
 			}
 		}
 	}()
 
 	// Ensure employer-clean finalizer firstly, employer-clean finalizer should be cleaned at the end
+	r.markImportIfNewlyManaged(employer)
 	updated, err := r.ensureEmployerCleanFlz(ctx, employer)
 	if err != nil {
 		logger.Error(err, "add employer clean finalizer failed")
@@ -187,59 +293,170 @@ func (r *Consist) Reconcile(ctx context.Context, request reconcile.Request) (rec
 		return reconcile.Result{}, nil
 	}
 
-	isExpectedClean, err := r.ensureExpectedFinalizer(ctx, employer)
+	// Employees' expected finalizers are not ensured while import blocks convergence
+	importBlocking := r.isImportBlocking(employer)
+	var isExpectedClean bool
+	if !importBlocking {
+		isExpectedClean, err = r.ensureExpectedFinalizer(ctx, employer)
+		if err != nil {
+			logger.Error(err, "ensure employees expected finalizer failed")
+			r.recorder.Eventf(employer, corev1.EventTypeWarning, EnsureExpectedFinalizerFailed,
+				"ensure employees expected finalizer failed: %s", err.Error())
+			return reconcile.Result{}, err
+		}
+	}
+
+	// Requeue instead of calling backend provider while circuit breaker open
+	if r.governor != nil {
+		if retryAfter := r.governor.retryAfter(); retryAfter > 0 {
+			r.recorder.Eventf(employer, corev1.EventTypeWarning, ProviderCircuitOpen,
+				"circuit breaker of backend provider open, requeue after %s", retryAfter)
+			return reconcile.Result{RequeueAfter: retryAfter}, nil
+		}
+	}
+
+	// Skip deleting backend resources if deletion policy is Retain/Orphan
+	if policy := getDeletionPolicy(employer); policy != DeletionPolicyDelete && !employer.GetDeletionTimestamp().IsZero() {
+		err = r.retainBackendResources(ctx, employer, policy)
+		if err != nil {
+			logger.Error(err, "retain backend resources failed")
+			r.recorder.Eventf(employer, corev1.EventTypeWarning, RetainBackendResourcesFailed,
+				"retain backend resources failed: %s", err.Error())
+			return reconcile.Result{}, err
+		}
+		if !isExpectedClean {
+			err = fmt.Errorf("employees' expected finalizer not cleaned")
+			return reconcile.Result{}, err
+		}
+		err = r.cleanEmployerCleanFinalizer(ctx, employer)
+		if err != nil {
+			logger.Error(err, "clean employer clean-finalizer failed")
+			r.recorder.Eventf(employer, corev1.EventTypeWarning, CleanEmployerCleanFinalizerFailed,
+				"clean employer clean-finalizer failed: %s", err.Error())
+			return reconcile.Result{}, err
+		}
+		r.recorder.Event(employer, corev1.EventTypeNormal, CleanEmployerCleanFinalizerSucceed,
+			"clean employer clean finalizer succeed")
+		return reconcile.Result{}, nil
+	}
+
+	// Adopt resources on backend provider and wait for confirmation if employer is importing
+	importFinished, err := r.reconcileImport(ctx, employer)
 	if err != nil {
-		logger.Error(err, "ensure employees expected finalizer failed")
-		r.recorder.Eventf(employer, corev1.EventTypeWarning, EnsureExpectedFinalizerFailed,
-			"ensure employees expected finalizer failed: %s", err.Error())
+		logger.Error(err, "import employer failed")
+		r.recorder.Eventf(employer, corev1.EventTypeWarning, AdoptBackendResourcesFailed,
+			"import employer failed: %s", err.Error())
 		return reconcile.Result{}, err
 	}
+	if !importFinished {
+		return reconcile.Result{}, nil
+	}
+	if importBlocking {
+		// requeue to ensure employees' expected finalizers before converging
+		return reconcile.Result{Requeue: true}, nil
+	}
+
+	hooks, hooksImplemented := r.adapter.(ReconcileHooks)
+	var hookResult HookResult
+	var hookRequeueAfter time.Duration
+	if finally, ok := r.adapter.(ReconcileFinallyHook); ok {
+		defer func() {
+			finally.Finally(ctx, employer, err)
+		}()
+	}
+	if hooksImplemented {
+		hookResult, err = hooks.PreSyncEmployer(ctx, employer)
+		if stop, result := r.handleHookResult(employer, "PreSyncEmployer", hookResult, err, &hookRequeueAfter); stop {
+			return result, err
+		}
+	}
 
 	// Sync employer
-	expectedEmployer, err := r.adapter.GetExpectedEmployer(ctx, employer)
+	expectedEmployer, err := r.provider.GetExpectedEmployer(ctx, employer)
 	if err != nil {
 		logger.Error(err, "get expect employer failed")
 		r.recorder.Eventf(employer, corev1.EventTypeWarning, GetExpectedEmployerFailed,
 			"get expect employer failed: %s", err.Error())
 		return reconcile.Result{}, err
 	}
-	currentEmployer, err := r.adapter.GetCurrentEmployer(ctx, employer)
+	currentEmployer, err := r.provider.GetCurrentEmployer(ctx, employer)
 	if err != nil {
 		logger.Error(err, "get current employer failed")
 		r.recorder.Eventf(employer, corev1.EventTypeWarning, GetCurrentEmployerFailed,
 			"get current employer failed: %s", err.Error())
 		return reconcile.Result{}, err
 	}
-	isCleanEmployer, syncEmployerFailedExist, cudEmployerResults, err := r.syncEmployer(ctx, employer, expectedEmployer, currentEmployer)
+	toCudEmployer, err := r.diffEmployer(employer, expectedEmployer, currentEmployer)
+	if err != nil {
+		logger.Error(err, "diff employer failed")
+		r.recorder.Eventf(employer, corev1.EventTypeWarning, SyncEmployerFailed,
+			"diff employer failed: %s", err.Error())
+		return reconcile.Result{}, err
+	}
+	// Duplicated ids are reported once employees diffed, unless reconcile should fail here
+	if len(toCudEmployer.DuplicatedIds) != 0 && r.getDuplicateIdPolicy() == DuplicateIdPolicyFail {
+		err = r.handleDuplicateIds(ctx, employer, toCudEmployer.DuplicatedIds, nil)
+		if err != nil {
+			logger.Error(err, "handle duplicated employer ids failed")
+			return reconcile.Result{}, err
+		}
+	}
+	isCleanEmployer, syncEmployerFailedExist, cudEmployerResults, err := r.syncEmployer(ctx, employer, toCudEmployer)
 	if err != nil {
 		logger.Error(err, "sync employer failed")
 		r.recorder.Eventf(employer, corev1.EventTypeWarning, SyncEmployerFailed,
 			"sync employer failed: %s", err.Error())
 		return reconcile.Result{}, err
 	}
+	if hooksImplemented {
+		hookResult, err = hooks.PostSyncEmployer(ctx, employer, toCudEmployer, cudEmployerResults)
+		if stop, result := r.handleHookResult(employer, "PostSyncEmployer", hookResult, err, &hookRequeueAfter); stop {
+			return result, err
+		}
+	}
 
 	// Sync employees
-	expectedEmployees, err := r.adapter.GetExpectedEmployee(ctx, employer)
+	expectedEmployees, err := r.provider.GetExpectedEmployee(ctx, employer)
 	if err != nil {
 		logger.Error(err, "get expect employees failed")
 		r.recorder.Eventf(employer, corev1.EventTypeWarning, GetExpectedEmployeesFailed,
 			"get expect employees failed: %s", err.Error())
 		return reconcile.Result{}, err
 	}
-	currentEmployees, err := r.adapter.GetCurrentEmployee(ctx, employer)
+	currentEmployees, err := r.provider.GetCurrentEmployee(ctx, employer)
 	if err != nil {
 		logger.Error(err, "get current employees failed")
 		r.recorder.Eventf(employer, corev1.EventTypeWarning, GetCurrentEmployeesFailed,
 			"get current employees failed: %s", err.Error())
 		return reconcile.Result{}, err
 	}
-	isCleanEmployee, syncEmployeeFailedExist, cudEmployeeResults, err := r.syncEmployees(ctx, employer, expectedEmployees, currentEmployees)
+	toCudEmployees, err := r.diffEmployees(employer, expectedEmployees, currentEmployees)
+	if err != nil {
+		logger.Error(err, "diff employees failed")
+		r.recorder.Eventf(employer, corev1.EventTypeWarning, SyncEmployeesFailed,
+			"diff employees failed: %s", err.Error())
+		return reconcile.Result{}, err
+	}
+	err = r.handleDuplicateIds(ctx, employer, toCudEmployer.DuplicatedIds, toCudEmployees.DuplicatedIds)
+	if err != nil {
+		logger.Error(err, "handle duplicated employee ids failed")
+		return reconcile.Result{}, err
+	}
+	isCleanEmployee, syncEmployeeFailedExist, cudEmployeeResults, err := r.syncEmployees(ctx, employer, toCudEmployees)
 	if err != nil {
 		logger.Error(err, "sync employees failed")
 		r.recorder.Eventf(employer, corev1.EventTypeWarning, SyncEmployeesFailed,
 			"sync employees failed: %s", err.Error())
 		return reconcile.Result{}, err
 	}
+	if hooksImplemented {
+		hookResult, err = hooks.PostSyncEmployees(ctx, employer, toCudEmployees, cudEmployeeResults)
+		if stop, result := r.handleHookResult(employer, "PostSyncEmployees", hookResult, err, &hookRequeueAfter); stop {
+			return result, err
+		}
+	}
+
+	r.reportUnmanagedSkipped(employer, cudEmployerResults.Unmanaged, cudEmployeeResults.Unmanaged)
 
 	if isCleanEmployer && isCleanEmployee && isExpectedClean && !employer.GetDeletionTimestamp().IsZero() {
 		err = r.cleanEmployerCleanFinalizer(ctx, employer)
@@ -269,8 +486,9 @@ func (r *Consist) Reconcile(ctx context.Context, request reconcile.Request) (rec
 		if requeueOptionsImplemented {
 			return reconcile.Result{RequeueAfter: requeueOptions.EmployeeSyncRequeueInterval()}, nil
 		}
-		return reconcile.Result{}, fmt.Errorf("employer or employees synced failed exist")
+		err = fmt.Errorf("employer or employees synced failed exist")
+		return reconcile.Result{}, err
 	}
 
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: hookRequeueAfter}, nil
 }
@@ -64,7 +64,7 @@ var _ = Describe("resource-consist-controller", func() {
 				Labels: map[string]string{
 					v1alpha1.ControlledByKusionStackLabelKey: "true",
 				},
-				Finalizers: []string{cleanFinalizerPrefix + "resource-consist-ut-svc"},
+				Finalizers: []string{utils.GenerateOldCleanFinalizer("resource-consist-ut-svc")},
 			},
 			Spec: corev1.ServiceSpec{
 				Ports: []corev1.ServicePort{
@@ -98,7 +98,7 @@ var _ = Describe("resource-consist-controller", func() {
 					}
 				}
 				for _, flz := range svcTmp.GetFinalizers() {
-					if flz == cleanFinalizer {
+					if flz == utils.GenerateCleanFinalizer() {
 						return true
 					}
 				}
@@ -315,7 +315,7 @@ var _ = Describe("resource-consist-controller", func() {
 				if err != nil {
 					return false
 				}
-				return svcTmp.GetAnnotations()[expectedFinalizerAddedAnnoKey] == pod.Name
+				return svcTmp.GetAnnotations()[expectedFinalizerAddedAnnoKey()] == pod.Name
 			}, 3*time.Second, 100*time.Millisecond).Should(BeTrue())
 		})
 
@@ -738,7 +738,7 @@ var _ = Describe("resource-consist-controller", func() {
 				if err != nil {
 					return false
 				}
-				return svcTmp.GetAnnotations()[expectedFinalizerAddedAnnoKey] == pod3.Name
+				return svcTmp.GetAnnotations()[expectedFinalizerAddedAnnoKey()] == pod3.Name
 			}, 3*time.Second, 100*time.Millisecond).Should(BeTrue())
 		})
 
@@ -849,7 +849,7 @@ var _ = Describe("resource-consist-controller", func() {
 
 				return !strings.Contains(podTmp.GetAnnotations()[v1alpha1.PodAvailableConditionsAnnotation],
 					"Service/default/resource-consist-ut-svc-3") && !lifecycleFlzExist && !pod3RsExist &&
-					!strings.Contains(svcTmp.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey], pod3.Name)
+					!strings.Contains(svcTmp.GetAnnotations()[lifecycleFinalizerRecordedAnnoKey()], pod3.Name)
 			}, 3*time.Second, 100*time.Millisecond).Should(BeTrue())
 		})
 
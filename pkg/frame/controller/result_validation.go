@@ -0,0 +1,147 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// partitionViolations records how succ/fail returned by CUD methods of adapter fail to partition the requested
+type partitionViolations struct {
+	// Unknown are ids never requested, or empty ids of nil items
+	Unknown []string
+	// Duplicated are ids returned more than once, items in both succ and fail are treated as failed
+	Duplicated []string
+	// Unaccounted are ids requested but returned in neither succ nor fail, treated as failed
+	Unaccounted []string
+}
+
+func (v partitionViolations) violated() bool {
+	return len(v.Unknown) != 0 || len(v.Duplicated) != 0 || len(v.Unaccounted) != 0
+}
+
+// partitionResults makes succ and fail partition requested, every requested item appears exactly once in either of
+// them. Unknown items are dropped, duplicated items are kept once and failed takes precedence, unaccounted items are
+// treated as failed.
+func partitionResults[T any](requested, succ, fail []T, id func(T) string) ([]T, []T, partitionViolations) {
+	var violations partitionViolations
+	requestedItems := make(map[string]T, len(requested))
+	for _, item := range requested {
+		requestedItems[id(item)] = item
+	}
+	if len(requestedItems) == 0 && len(succ) == 0 && len(fail) == 0 {
+		return succ, fail, violations
+	}
+
+	failed := make(map[string]bool, len(fail))
+	validFail := make([]T, 0, len(fail))
+	for _, item := range fail {
+		itemId := id(item)
+		if _, ok := requestedItems[itemId]; !ok {
+			violations.Unknown = append(violations.Unknown, itemId)
+			continue
+		}
+		if failed[itemId] {
+			violations.Duplicated = append(violations.Duplicated, itemId)
+			continue
+		}
+		failed[itemId] = true
+		validFail = append(validFail, item)
+	}
+
+	succeeded := make(map[string]bool, len(succ))
+	validSucc := make([]T, 0, len(succ))
+	for _, item := range succ {
+		itemId := id(item)
+		if _, ok := requestedItems[itemId]; !ok {
+			violations.Unknown = append(violations.Unknown, itemId)
+			continue
+		}
+		if failed[itemId] || succeeded[itemId] {
+			violations.Duplicated = append(violations.Duplicated, itemId)
+			continue
+		}
+		succeeded[itemId] = true
+		validSucc = append(validSucc, item)
+	}
+
+	for _, item := range requested {
+		itemId := id(item)
+		if !failed[itemId] && !succeeded[itemId] {
+			violations.Unaccounted = append(violations.Unaccounted, itemId)
+			failed[itemId] = true
+			validFail = append(validFail, item)
+		}
+	}
+	return validSucc, validFail, violations
+}
+
+func employerIdOf(employer IEmployer) string {
+	if employer == nil {
+		return ""
+	}
+	return employer.GetEmployerId()
+}
+
+func employeeIdOf(employee IEmployee) string {
+	if employee == nil {
+		return ""
+	}
+	return employee.GetEmployeeId()
+}
+
+// validateEmployerResults makes succ and fail returned by method partition requested, see partitionResults
+func (r *Consist) validateEmployerResults(employer client.Object, method string, requested, succ, fail []IEmployer) ([]IEmployer, []IEmployer) {
+	validSucc, validFail, violations := partitionResults(requested, succ, fail, employerIdOf)
+	r.reportPartitionViolations(employer, method, violations)
+	return validSucc, validFail
+}
+
+// validateEmployeeResults makes succ and fail returned by method partition requested, see partitionResults
+func (r *Consist) validateEmployeeResults(employer client.Object, method string, requested, succ, fail []IEmployee) ([]IEmployee, []IEmployee) {
+	validSucc, validFail, violations := partitionResults(requested, succ, fail, employeeIdOf)
+	r.reportPartitionViolations(employer, method, violations)
+	return validSucc, validFail
+}
+
+func (r *Consist) reportPartitionViolations(employer client.Object, method string, violations partitionViolations) {
+	if !violations.violated() {
+		return
+	}
+	countPartitionViolations(r.adapter.GetControllerName(), method, violations)
+	r.recorder.Eventf(employer, corev1.EventTypeWarning, InvalidAdapterResults,
+		"succ/fail returned by %s don't partition requested, unknown: %v, duplicated: %v, unaccounted(treated as failed): %v",
+		method, violations.Unknown, violations.Duplicated, violations.Unaccounted)
+}
+
+func countPartitionViolations(controllerName, method string, violations partitionViolations) {
+	invalidAdapterResults.WithLabelValues(controllerName, method, "unknown").Add(float64(len(violations.Unknown)))
+	invalidAdapterResults.WithLabelValues(controllerName, method, "duplicated").Add(float64(len(violations.Duplicated)))
+	invalidAdapterResults.WithLabelValues(controllerName, method, "unaccounted").Add(float64(len(violations.Unaccounted)))
+}
+
+// dropInvalidItems drops nil items and items with empty id, returns count of items dropped as well
+func dropInvalidItems[T any](items []T, id func(T) string) ([]T, int) {
+	valid := make([]T, 0, len(items))
+	for _, item := range items {
+		if id(item) != "" {
+			valid = append(valid, item)
+		}
+	}
+	return valid, len(items) - len(valid)
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionResults(t *testing.T) {
+	pod := func(id string) IEmployee {
+		return &DemoPodStatus{EmployeeId: id}
+	}
+	requested := []IEmployee{pod("a"), pod("b"), pod("c"), pod("d")}
+	succ := []IEmployee{pod("a"), pod("a"), pod("b"), pod("x"), nil}
+	fail := []IEmployee{pod("b")}
+
+	validSucc, validFail, violations := partitionResults(requested, succ, fail, employeeIdOf)
+	assert.Equal(t, []string{"a"}, employeeIds(validSucc))
+	assert.Equal(t, []string{"b", "c", "d"}, employeeIds(validFail))
+	assert.Equal(t, []string{"x", ""}, violations.Unknown)
+	assert.Equal(t, []string{"a", "b"}, violations.Duplicated)
+	assert.Equal(t, []string{"c", "d"}, violations.Unaccounted)
+
+	validSucc, validFail, violations = partitionResults(requested, requested[:2], requested[2:], employeeIdOf)
+	assert.Len(t, validSucc, 2)
+	assert.Len(t, validFail, 2)
+	assert.False(t, violations.violated())
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type reconcileDeadlineKey struct{}
+
+type reconcileBudget struct {
+	budget   time.Duration
+	deadline time.Time
+}
+
+// withReconcileBudget records the deadline of adapter calls in one reconcile, no deadline if budget isn't positive
+func withReconcileBudget(ctx context.Context, budget time.Duration) context.Context {
+	if budget <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, reconcileDeadlineKey{}, reconcileBudget{budget: budget, deadline: time.Now().Add(budget)})
+}
+
+// timeoutLimit is the limit bounding a call, call timeout or reconcile budget whichever ends earlier
+type timeoutLimit struct {
+	name  string
+	value time.Duration
+}
+
+// CallTimeoutError is returned if a call to adapter exceeded its deadline, see ReconcileTimeoutOptions
+type CallTimeoutError struct {
+	Method string
+	Err    error
+}
+
+func (e *CallTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out, err: %v", e.Method, e.Err)
+}
+
+func (e *CallTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// timedAdapter calls methods of adapter with deadlines configured by ReconcileTimeoutOptions. Get* calls exceeding the
+// deadline are abandoned so that workers won't be blocked by hung calls ignoring ctx, their results are discarded and
+// the next reconcile converges from what's current then. CUD calls might be partially done, so they are passed the
+// deadline via ctx and always waited for.
+type timedAdapter struct {
+	ReconcileAdapter
+	timeoutOptions ReconcileTimeoutOptions
+	recorder       record.EventRecorder
+}
+
+type cudResults[T any] struct {
+	succ []T
+	fail []T
+}
+
+func timedCall[T any](ctx context.Context, a *timedAdapter, employer client.Object, method string,
+	fn func(ctx context.Context) (T, error)) (T, error) {
+	timeout := a.timeoutOptions.GetCallTimeout(method)
+	limit := timeoutLimit{name: "call timeout", value: timeout}
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if budget, ok := ctx.Value(reconcileDeadlineKey{}).(reconcileBudget); ok {
+		if deadline, ok := ctx.Deadline(); !ok || budget.deadline.Before(deadline) {
+			limit = timeoutLimit{name: "reconcile budget", value: budget.budget}
+		}
+		ctx, cancel = context.WithDeadline(ctx, budget.deadline)
+		defer cancel()
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		return fn(ctx)
+	}
+
+	if !strings.HasPrefix(method, "Get") {
+		value, err := fn(ctx)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return value, a.timedOut(employer, method, limit, ctx.Err())
+		}
+		return value, err
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		value, err := fn(ctx)
+		resultCh <- result{value: value, err: err}
+	}()
+
+	var zero T
+	select {
+	case res := <-resultCh:
+		if res.err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return res.value, res.err
+		}
+	case <-ctx.Done():
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return zero, ctx.Err()
+		}
+	}
+
+	return zero, a.timedOut(employer, method, limit, ctx.Err())
+}
+
+func (a *timedAdapter) timedOut(employer client.Object, method string, limit timeoutLimit, err error) error {
+	adapterCallTimeouts.WithLabelValues(a.GetControllerName(), method).Inc()
+	a.recorder.Eventf(employer, corev1.EventTypeWarning, AdapterCallTimeout,
+		"%s of %s exceeded deadline, %s: %s", method, a.GetControllerName(), limit.name, limit.value)
+	return &CallTimeoutError{Method: method, Err: err}
+}
+
+func (a *timedAdapter) GetExpectedEmployer(ctx context.Context, employer client.Object) ([]IEmployer, error) {
+	return timedCall(ctx, a, employer, "GetExpectedEmployer", func(ctx context.Context) ([]IEmployer, error) {
+		return a.ReconcileAdapter.GetExpectedEmployer(ctx, employer)
+	})
+}
+
+func (a *timedAdapter) GetCurrentEmployer(ctx context.Context, employer client.Object) ([]IEmployer, error) {
+	return timedCall(ctx, a, employer, "GetCurrentEmployer", func(ctx context.Context) ([]IEmployer, error) {
+		return a.ReconcileAdapter.GetCurrentEmployer(ctx, employer)
+	})
+}
+
+func (a *timedAdapter) CreateEmployer(ctx context.Context, employer client.Object, toCreates []IEmployer) ([]IEmployer, []IEmployer, error) {
+	res, err := timedCall(ctx, a, employer, "CreateEmployer", func(ctx context.Context) (cudResults[IEmployer], error) {
+		succ, fail, err := a.ReconcileAdapter.CreateEmployer(ctx, employer, toCreates)
+		return cudResults[IEmployer]{succ: succ, fail: fail}, err
+	})
+	return res.succ, res.fail, err
+}
+
+func (a *timedAdapter) UpdateEmployer(ctx context.Context, employer client.Object, toUpdates []IEmployer) ([]IEmployer, []IEmployer, error) {
+	res, err := timedCall(ctx, a, employer, "UpdateEmployer", func(ctx context.Context) (cudResults[IEmployer], error) {
+		succ, fail, err := a.ReconcileAdapter.UpdateEmployer(ctx, employer, toUpdates)
+		return cudResults[IEmployer]{succ: succ, fail: fail}, err
+	})
+	return res.succ, res.fail, err
+}
+
+func (a *timedAdapter) DeleteEmployer(ctx context.Context, employer client.Object, toDeletes []IEmployer) ([]IEmployer, []IEmployer, error) {
+	res, err := timedCall(ctx, a, employer, "DeleteEmployer", func(ctx context.Context) (cudResults[IEmployer], error) {
+		succ, fail, err := a.ReconcileAdapter.DeleteEmployer(ctx, employer, toDeletes)
+		return cudResults[IEmployer]{succ: succ, fail: fail}, err
+	})
+	return res.succ, res.fail, err
+}
+
+func (a *timedAdapter) GetExpectedEmployee(ctx context.Context, employer client.Object) ([]IEmployee, error) {
+	return timedCall(ctx, a, employer, "GetExpectedEmployee", func(ctx context.Context) ([]IEmployee, error) {
+		return a.ReconcileAdapter.GetExpectedEmployee(ctx, employer)
+	})
+}
+
+func (a *timedAdapter) GetCurrentEmployee(ctx context.Context, employer client.Object) ([]IEmployee, error) {
+	return timedCall(ctx, a, employer, "GetCurrentEmployee", func(ctx context.Context) ([]IEmployee, error) {
+		return a.ReconcileAdapter.GetCurrentEmployee(ctx, employer)
+	})
+}
+
+func (a *timedAdapter) CreateEmployees(ctx context.Context, employer client.Object, toCreates []IEmployee) ([]IEmployee, []IEmployee, error) {
+	res, err := timedCall(ctx, a, employer, "CreateEmployees", func(ctx context.Context) (cudResults[IEmployee], error) {
+		succ, fail, err := a.ReconcileAdapter.CreateEmployees(ctx, employer, toCreates)
+		return cudResults[IEmployee]{succ: succ, fail: fail}, err
+	})
+	return res.succ, res.fail, err
+}
+
+func (a *timedAdapter) UpdateEmployees(ctx context.Context, employer client.Object, toUpdates []IEmployee) ([]IEmployee, []IEmployee, error) {
+	res, err := timedCall(ctx, a, employer, "UpdateEmployees", func(ctx context.Context) (cudResults[IEmployee], error) {
+		succ, fail, err := a.ReconcileAdapter.UpdateEmployees(ctx, employer, toUpdates)
+		return cudResults[IEmployee]{succ: succ, fail: fail}, err
+	})
+	return res.succ, res.fail, err
+}
+
+func (a *timedAdapter) DeleteEmployees(ctx context.Context, employer client.Object, toDeletes []IEmployee) ([]IEmployee, []IEmployee, error) {
+	res, err := timedCall(ctx, a, employer, "DeleteEmployees", func(ctx context.Context) (cudResults[IEmployee], error) {
+		succ, fail, err := a.ReconcileAdapter.DeleteEmployees(ctx, employer, toDeletes)
+		return cudResults[IEmployee]{succ: succ, fail: fail}, err
+	})
+	return res.succ, res.fail, err
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+type testTimeoutOptions struct {
+	callTimeout time.Duration
+}
+
+func (o *testTimeoutOptions) GetCallTimeout(string) time.Duration {
+	return o.callTimeout
+}
+
+func (o *testTimeoutOptions) GetReconcileTimeout() time.Duration {
+	return 0
+}
+
+func TestTimedCallAbandonHungCall(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	a := &timedAdapter{
+		ReconcileAdapter: &DemoControllerAdapter{},
+		timeoutOptions:   &testTimeoutOptions{callTimeout: 20 * time.Millisecond},
+		recorder:         recorder,
+	}
+	hung := make(chan struct{})
+	defer close(hung)
+
+	start := time.Now()
+	_, err := timedCall(context.Background(), a, &corev1.Service{}, "GetCurrentEmployee",
+		func(ctx context.Context) ([]IEmployee, error) {
+			<-hung
+			return nil, nil
+		})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Contains(t, <-recorder.Events, "call timeout: 20ms")
+
+	// reconcile budget bounds calls without call timeout
+	a.timeoutOptions = &testTimeoutOptions{}
+	ctx := withReconcileBudget(context.Background(), 20*time.Millisecond)
+	_, err = timedCall(ctx, a, &corev1.Service{}, "GetCurrentEmployee",
+		func(ctx context.Context) ([]IEmployee, error) {
+			<-hung
+			return nil, nil
+		})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, <-recorder.Events, "reconcile budget: 20ms")
+}
+
+func TestTimedCallWaitForWrites(t *testing.T) {
+	a := &timedAdapter{
+		ReconcileAdapter: &DemoControllerAdapter{},
+		timeoutOptions:   &testTimeoutOptions{callTimeout: 20 * time.Millisecond},
+		recorder:         record.NewFakeRecorder(10),
+	}
+	returned := false
+	res, err := timedCall(context.Background(), a, &corev1.Service{}, "DeleteEmployees",
+		func(ctx context.Context) (cudResults[IEmployee], error) {
+			<-ctx.Done()
+			time.Sleep(20 * time.Millisecond)
+			returned = true
+			return cudResults[IEmployee]{succ: []IEmployee{&DemoPodStatus{EmployeeId: "deleted"}}}, ctx.Err()
+		})
+	assert.True(t, returned)
+	assert.Len(t, res.succ, 1)
+	var timeout *CallTimeoutError
+	assert.True(t, errors.As(err, &timeout))
+	assert.Equal(t, "DeleteEmployees", timeout.Method)
+
+	// timeouts are failures of backend provider unless ignored
+	g := NewCallGovernor(CallGovernorOptions{Name: "test", QPS: 1000, FailureThreshold: 1})
+	assert.Error(t, g.call(context.Background(), func() error { return err }))
+	assert.NotZero(t, g.retryAfter())
+	g = NewCallGovernor(CallGovernorOptions{Name: "test", QPS: 1000, FailureThreshold: 1, IgnoreCallTimeouts: true})
+	assert.Error(t, g.call(context.Background(), func() error { return err }))
+	assert.Zero(t, g.retryAfter())
+}
@@ -75,6 +75,7 @@ type StatusRecordOptions interface {
 
 	// RecordErrorConditions records error conditions, called at the end of failed reconcile.
 	// usually, something recorded in RecordErrorConditions should be erased in RecordStatuses.
+	// err is classified, it's the ErrThrottled/ErrTerminal/ErrConflict/ErrNotFound if one of them in the chain.
 	RecordErrorConditions(ctx context.Context, employer client.Object, err error) error
 }
 
@@ -95,12 +96,92 @@ type ReconcileLifecycleOptions interface {
 	GetSelectedEmployeeNames(ctx context.Context, employer client.Object) ([]string, error)
 }
 
+// ReconcileTimeoutOptions bounds calls to methods of ReconcileAdapter, so that hung calls to backend provider won't
+// block workers. Get* calls exceeding the deadline are abandoned and fail the reconcile, while CUD calls are passed the
+// deadline via ctx and waited for since they might be partially done, so CUD methods should respect ctx.
+type ReconcileTimeoutOptions interface {
+	// GetCallTimeout returns timeout of each call to the method of ReconcileAdapter, named like "GetCurrentEmployee",
+	// no timeout if zero
+	GetCallTimeout(method string) time.Duration
+	// GetReconcileTimeout returns the overall budget of calls to ReconcileAdapter in one reconcile, no budget if zero
+	GetReconcileTimeout() time.Duration
+}
+
+// ReconcileHooks runs custom logic at fixed points of reconcile, like warming a cache, locking a shared LB or
+// notifying a CMDB. Reconcile fails if a hook returns error, and stops if a hook aborts it.
+type ReconcileHooks interface {
+	// PreSyncEmployer is called before expected/current employer got from backend provider
+	PreSyncEmployer(ctx context.Context, employer client.Object) (HookResult, error)
+	// PostSyncEmployer is called after employer synced and before employees synced
+	PostSyncEmployer(ctx context.Context, employer client.Object, toCudEmployer ToCUDEmployer,
+		cudEmployerResults CUDEmployerResults) (HookResult, error)
+	// PostSyncEmployees is called after employees synced and their lifecycle finalizers ensured
+	PostSyncEmployees(ctx context.Context, employer client.Object, toCudEmployees ToCUDEmployees,
+		cudEmployeeResults CUDEmployeeResults) (HookResult, error)
+}
+
+// ReconcileFinallyHook runs once the sync of employer and employees is done, whether it finished, stopped by a
+// hook or failed, err is the error reconcile returns, e.g. to release a lock taken in PreSyncEmployer
+type ReconcileFinallyHook interface {
+	Finally(ctx context.Context, employer client.Object, err error)
+}
+
+// HookResult decides how reconcile goes on after a hook of ReconcileHooks
+type HookResult struct {
+	// Abort stops the reconcile right after the hook
+	Abort bool
+	// RequeueAfter requeues employer after the duration once reconcile stopped or finished, the minimum one is used if
+	// more than one hook set it, no requeue if zero
+	RequeueAfter time.Duration
+}
+
 type ReconcileRequeueOptions interface {
 	// EmployeeSyncRequeueInterval returns requeue time interval if employee synced failed but no err
 	EmployeeSyncRequeueInterval() time.Duration
 }
 
+// DriftRecordOptions defines how drift recorded to employer in observe mode, see WithObserveOnly.
+// If not implemented, drift will be recorded as DriftConditionType condition to status if employer is Service.
+type DriftRecordOptions interface {
+	RecordDrift(ctx context.Context, employer client.Object, drift Drift) error
+}
+
+// ManagedEmployerLister enables garbage collection of resources on backend provider whose employer no longer exists,
+// see WithGarbageCollection.
+type ManagedEmployerLister interface {
+	// ListManagedEmployers enumerates resources on backend provider tagged with employer key, and returns employers
+	// rebuilt from the tags. At least namespace and name should be set, and the rebuilt employers will be passed to
+	// GetCurrentEmployer/GetCurrentEmployee/DeleteEmployer/DeleteEmployees if employer no longer exists.
+	ListManagedEmployers(ctx context.Context) ([]client.Object, error)
+	// MarkRetained marks resources of employer on backend provider as kept by policy, e.g. via tags of provider. It's
+	// called before clean finalizer removed from employer deleted under DeletionPolicyRetain/DeletionPolicyOrphan.
+	// Employers rebuilt by ListManagedEmployers from marked resources should carry DeletionPolicyAnnoKey of the policy,
+	// so that they are never collected.
+	MarkRetained(ctx context.Context, employer client.Object, policy DeletionPolicy) error
+}
+
+// ReconcileImportOptions enables import mode for employers whose resources on backend provider already exist,
+// like an LB created by hand. In the first reconcile of a newly managed employer, current employer/employees got from
+// backend provider are adopted instead of being deleted, and an import report is recorded to employer's anno.
+// Normal convergence starts only after operators confirm the import via ImportConfirmedAnnoKey, or at once if nothing
+// got from backend provider. Employees' expected finalizers are not ensured till then.
+type ReconcileImportOptions interface {
+	// AdoptEmployer/AdoptEmployees mark resources on backend provider as managed, e.g. via tags of provider,
+	// and return succeeded and failed ones.
+	AdoptEmployer(ctx context.Context, employer client.Object, toAdopts []IEmployer) ([]IEmployer, []IEmployer, error)
+	AdoptEmployees(ctx context.Context, employer client.Object, toAdopts []IEmployee) ([]IEmployee, []IEmployee, error)
+}
+
+// DiffOrderOptions supplies the order of employers/employees passed to CUD methods, see ReconcileAdapter
+type DiffOrderOptions interface {
+	LessEmployer(a, b IEmployer) bool
+	LessEmployee(a, b IEmployee) bool
+}
+
 // ReconcileAdapter is the interface that customized controllers should implement.
+// Ordering contract: employers/employees passed to CUD methods, and those in ToCUDEmployer/ToCUDEmployees, are sorted
+// by id, or by DiffOrderOptions if implemented(ties sorted by id), so identical expected/current give identical plans
+// regardless of the order returned by GetExpected*/GetCurrent*.
 type ReconcileAdapter interface {
 	GetControllerName() string
 
@@ -109,6 +190,7 @@ type ReconcileAdapter interface {
 	GetCurrentEmployer(ctx context.Context, employer client.Object) ([]IEmployer, error)
 
 	// CreateEmployer/UpdateEmployer/DeleteEmployer handles creation/update/deletion of resources related to employer on related backend provider
+	// returned succ and fail should partition the requested, items not accounted for are treated as failed
 	CreateEmployer(ctx context.Context, employer client.Object, toCreates []IEmployer) ([]IEmployer, []IEmployer, error)
 	UpdateEmployer(ctx context.Context, employer client.Object, toUpdates []IEmployer) ([]IEmployer, []IEmployer, error)
 	DeleteEmployer(ctx context.Context, employer client.Object, toDeletes []IEmployer) ([]IEmployer, []IEmployer, error)
@@ -118,6 +200,7 @@ type ReconcileAdapter interface {
 	GetCurrentEmployee(ctx context.Context, employer client.Object) ([]IEmployee, error)
 
 	// CreateEmployees/UpdateEmployees/DeleteEmployees handles creation/update/deletion of resources related to employee on related backend provider
+	// returned succ and fail should partition the requested, items not accounted for are treated as failed
 	CreateEmployees(ctx context.Context, employer client.Object, toCreates []IEmployee) ([]IEmployee, []IEmployee, error)
 	UpdateEmployees(ctx context.Context, employer client.Object, toUpdates []IEmployee) ([]IEmployee, []IEmployee, error)
 	DeleteEmployees(ctx context.Context, employer client.Object, toDeletes []IEmployee) ([]IEmployee, []IEmployee, error)
@@ -142,11 +225,22 @@ type IEmployee interface {
 	EmployeeEqual(employee IEmployee) (bool, error)
 }
 
+// IOwnership is optional for IEmployer/IEmployee. Resources on backend provider might be shared with others, like
+// backend servers added to the same LB by another team. If implemented, current employer/employees not managed by the
+// employer will never be deleted, and are reported as Unmanaged instead.
+type IOwnership interface {
+	IsManagedBy(employer client.Object) bool
+}
+
 type ToCUDEmployer struct {
 	ToCreate  []IEmployer
 	ToUpdate  []IEmployer
 	ToDelete  []IEmployer
 	Unchanged []IEmployer
+	// Unmanaged are current ones not managed by this employer, see IOwnership
+	Unmanaged []IEmployer
+	// DuplicatedIds are ids shared by more than one expected or current item, see DuplicateIdPolicy
+	DuplicatedIds []string
 }
 
 type CUDEmployerResults struct {
@@ -157,6 +251,9 @@ type CUDEmployerResults struct {
 	SuccDeleted []IEmployer
 	FailDeleted []IEmployer
 	Unchanged   []IEmployer
+	Unmanaged   []IEmployer
+	// DuplicatedIds are ids shared by more than one expected or current item, see DuplicateIdPolicy
+	DuplicatedIds []string
 }
 
 type ToCUDEmployees struct {
@@ -164,6 +261,10 @@ type ToCUDEmployees struct {
 	ToUpdate  []IEmployee
 	ToDelete  []IEmployee
 	Unchanged []IEmployee
+	// Unmanaged are current ones not managed by this employer, see IOwnership
+	Unmanaged []IEmployee
+	// DuplicatedIds are ids shared by more than one expected or current item, see DuplicateIdPolicy
+	DuplicatedIds []string
 }
 
 type CUDEmployeeResults struct {
@@ -174,6 +275,9 @@ type CUDEmployeeResults struct {
 	SuccDeleted []IEmployee
 	FailDeleted []IEmployee
 	Unchanged   []IEmployee
+	Unmanaged   []IEmployee
+	// DuplicatedIds are ids shared by more than one expected or current item, see DuplicateIdPolicy
+	DuplicatedIds []string
 }
 
 type PodEmployeeStatuses struct {
@@ -184,6 +288,65 @@ type PodEmployeeStatuses struct {
 	ExtraStatus interface{} `json:"extraStatus,omitempty"`
 }
 
+// Drift is the difference between expected and current employer/employees observed in observe mode,
+// including ids of resources that would be created/updated/deleted if not in observe mode.
+type Drift struct {
+	ToCreateEmployers []string `json:"toCreateEmployers,omitempty"`
+	ToUpdateEmployers []string `json:"toUpdateEmployers,omitempty"`
+	ToDeleteEmployers []string `json:"toDeleteEmployers,omitempty"`
+	ToCreateEmployees []string `json:"toCreateEmployees,omitempty"`
+	ToUpdateEmployees []string `json:"toUpdateEmployees,omitempty"`
+	ToDeleteEmployees []string `json:"toDeleteEmployees,omitempty"`
+}
+
+// ImportReportCounts counts the ids of ImportReport before truncation
+type ImportReportCounts struct {
+	AdoptedEmployers     int `json:"adoptedEmployers"`
+	FailAdoptedEmployers int `json:"failAdoptedEmployers"`
+	AdoptedEmployees     int `json:"adoptedEmployees"`
+	FailAdoptedEmployees int `json:"failAdoptedEmployees"`
+
+	ToCreateEmployers int `json:"toCreateEmployers"`
+	ToUpdateEmployers int `json:"toUpdateEmployers"`
+	ToDeleteEmployers int `json:"toDeleteEmployers"`
+	ToCreateEmployees int `json:"toCreateEmployees"`
+	ToUpdateEmployees int `json:"toUpdateEmployees"`
+	ToDeleteEmployees int `json:"toDeleteEmployees"`
+}
+
+// Drifted returns whether any resource drifted
+func (d Drift) Drifted() bool {
+	return len(d.ToCreateEmployers) != 0 || len(d.ToUpdateEmployers) != 0 || len(d.ToDeleteEmployers) != 0 ||
+		len(d.ToCreateEmployees) != 0 || len(d.ToUpdateEmployees) != 0 || len(d.ToDeleteEmployees) != 0
+}
+
+// ImportReport is recorded to employer's anno in import mode, includes counts and ids of adopted resources and what
+// will be done once import confirmed. At most importReportSampleSize ids are kept for each list to bound the anno size,
+// Truncated is set if any list is cut.
+type ImportReport struct {
+	Counts    ImportReportCounts `json:"counts"`
+	Truncated bool               `json:"truncated,omitempty"`
+
+	AdoptedEmployers     []string `json:"adoptedEmployers,omitempty"`
+	FailAdoptedEmployers []string `json:"failAdoptedEmployers,omitempty"`
+	AdoptedEmployees     []string `json:"adoptedEmployees,omitempty"`
+	FailAdoptedEmployees []string `json:"failAdoptedEmployees,omitempty"`
+
+	ToCreateEmployers []string `json:"toCreateEmployers,omitempty"`
+	ToUpdateEmployers []string `json:"toUpdateEmployers,omitempty"`
+	ToDeleteEmployers []string `json:"toDeleteEmployers,omitempty"`
+	ToCreateEmployees []string `json:"toCreateEmployees,omitempty"`
+	ToUpdateEmployees []string `json:"toUpdateEmployees,omitempty"`
+	ToDeleteEmployees []string `json:"toDeleteEmployees,omitempty"`
+}
+
+// DeletionPolicy defines how backend resources handled when employer deleted, set via DeletionPolicyAnnoKey
+type DeletionPolicy string
+
+// DuplicateIdPolicy decides what happens if more than one expected or current employer/employee share one id, like
+// two pods sharing a recycled ip when ip is the employee id
+type DuplicateIdPolicy string
+
 type PodExpectedFinalizerOps struct {
 	Name    string
 	Succeed bool
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// reportUnmanagedSkipped emits UnmanagedResourcesSkipped only if resources skipped changed since last reported for
+// employer, since they are skipped in every reconcile until tagged or removed out of band
+func (r *Consist) reportUnmanagedSkipped(employer client.Object, employers []IEmployer, employees []IEmployee) {
+	key := types.NamespacedName{Namespace: employer.GetNamespace(), Name: employer.GetName()}
+	if len(employers) == 0 && len(employees) == 0 {
+		r.unmanagedReported.Delete(key)
+		return
+	}
+	skippedEmployers, skippedEmployees := employerIds(employers), employeeIds(employees)
+	digest := unmanagedDigest(skippedEmployers, skippedEmployees)
+	if reported, ok := r.unmanagedReported.Load(key); ok && reported.(string) == digest {
+		return
+	}
+	r.unmanagedReported.Store(key, digest)
+	r.recorder.Eventf(employer, corev1.EventTypeNormal, UnmanagedResourcesSkipped,
+		"resources not managed by employer skipped, employer: %s, employees: %s",
+		idSample(skippedEmployers), idSample(skippedEmployees))
+}
+
+// unmanagedDigest identifies resources skipped by counts and hash of sorted ids, keeping memory per employer bounded
+func unmanagedDigest(employers, employees []string) string {
+	h := fnv.New64a()
+	for _, ids := range [][]string{employers, employees} {
+		sorted := append([]string(nil), ids...)
+		sort.Strings(sorted)
+		for _, id := range sorted {
+			h.Write([]byte(id))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{1})
+	}
+	return fmt.Sprintf("%d/%d/%x", len(employers), len(employees), h.Sum64())
+}
+
+// forgetUnmanagedSkipped forgets resources skipped last reported for employer not found
+func (r *Consist) forgetUnmanagedSkipped(namespace, name string) {
+	r.unmanagedReported.Delete(types.NamespacedName{Namespace: namespace, Name: name})
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+func unmanagedSkippedEvents(recorder *record.FakeRecorder) int {
+	count := 0
+	for {
+		select {
+		case event := <-recorder.Events:
+			if strings.Contains(event, UnmanagedResourcesSkipped) {
+				count++
+			}
+		default:
+			return count
+		}
+	}
+}
+
+func TestUnmanagedSkippedReportedOnChange(t *testing.T) {
+	adapter := newMemoryAdapter([]string{"svc"}, "pod-a")
+	adapter.employees["foreign-a"] = &ownedDemoPodStatus{DemoPodStatus: DemoPodStatus{EmployeeId: "foreign-a"}, Owner: "other"}
+	svc := newTestService("svc", nil, utils.GenerateCleanFinalizer())
+	r := newFakeConsist(adapter, []client.Object{svc})
+	recorder := record.NewFakeRecorder(100)
+	r.recorder = recorder
+
+	for i := 0; i < 3; i++ {
+		_, err := reconcileEmployer(t, r, svc)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, unmanagedSkippedEvents(recorder))
+	assert.Equal(t, []string{"foreign-a", "pod-a"}, adapter.employeeIds())
+
+	adapter.employees["foreign-b"] = &ownedDemoPodStatus{DemoPodStatus: DemoPodStatus{EmployeeId: "foreign-b"}, Owner: "other"}
+	for i := 0; i < 2; i++ {
+		_, err := reconcileEmployer(t, r, svc)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, unmanagedSkippedEvents(recorder))
+
+	// reported again once skipped resources gone and back
+	delete(adapter.employees, "foreign-a")
+	delete(adapter.employees, "foreign-b")
+	_, err := reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	adapter.employees["foreign-a"] = &ownedDemoPodStatus{DemoPodStatus: DemoPodStatus{EmployeeId: "foreign-a"}, Owner: "other"}
+	_, err = reconcileEmployer(t, r, svc)
+	require.NoError(t, err)
+	assert.Equal(t, 1, unmanagedSkippedEvents(recorder))
+}
+
+func TestUnmanagedDigest(t *testing.T) {
+	assert.Equal(t, unmanagedDigest([]string{"a"}, []string{"b", "c"}), unmanagedDigest([]string{"a"}, []string{"c", "b"}))
+	assert.NotEqual(t, unmanagedDigest([]string{"a"}, []string{"b"}), unmanagedDigest(nil, []string{"a", "b"}))
+	assert.NotEqual(t, unmanagedDigest(nil, []string{"ab"}), unmanagedDigest(nil, []string{"a", "b"}))
+}
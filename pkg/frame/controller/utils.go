@@ -27,6 +27,12 @@ import (
 	"kusionstack.io/kube-api/apps/v1alpha1"
 )
 
+// isManagedBy returns true if resource doesn't implement IOwnership
+func isManagedBy(resource interface{}, employer client.Object) bool {
+	ownership, ok := resource.(IOwnership)
+	return !ok || ownership.IsManagedBy(employer)
+}
+
 func isPod(obj client.Object) bool {
 	_, ok := obj.(*corev1.Pod)
 	return ok
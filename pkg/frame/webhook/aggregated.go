@@ -0,0 +1,178 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	errors2 "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// AggregationFailurePolicy decides what happens to an admission if some of the aggregated adapters fail
+type AggregationFailurePolicy string
+
+const (
+	// AggregationFailClosed fails the admission if any adapter fails
+	AggregationFailClosed AggregationFailurePolicy = "FailClosed"
+	// AggregationFailOpen ignores adapters failed, expected finalizers of the others are still patched
+	AggregationFailOpen AggregationFailurePolicy = "FailOpen"
+)
+
+var (
+	_ WebhookAdapter         = &aggregatedWebhookAdapter{}
+	_ EmployeeKindsAdapter   = &aggregatedWebhookAdapter{}
+	_ ExpectedFinalizerStore = &aggregatedWebhookAdapter{}
+)
+
+// aggregatedWebhookAdapter serves several adapters in one path, calling them concurrently
+type aggregatedWebhookAdapter struct {
+	name     string
+	policy   AggregationFailurePolicy
+	adapters []WebhookAdapter
+}
+
+// NewAggregatedWebhookAdapter returns a WebhookAdapter merging employers of adapters, so that one admission round-trip
+// patches expected finalizers of all of them. Adapters failed are handled according to policy, FailClosed by default.
+func NewAggregatedWebhookAdapter(name string, policy AggregationFailurePolicy, adapters ...WebhookAdapter) WebhookAdapter {
+	if policy != AggregationFailOpen {
+		policy = AggregationFailClosed
+	}
+	return &aggregatedWebhookAdapter{
+		name:     name,
+		policy:   policy,
+		adapters: adapters,
+	}
+}
+
+// AddAggregatedToMgr registers adapters in one path named name, instead of calling AddToMgr for each of them
+func AddAggregatedToMgr(mgr manager.Manager, name string, policy AggregationFailurePolicy, adapters ...WebhookAdapter) error {
+	return AddToMgr(mgr, NewAggregatedWebhookAdapter(name, policy, adapters...))
+}
+
+func (a *aggregatedWebhookAdapter) Name() string {
+	return a.name
+}
+
+// EmployeeGVKs returns kinds served by any of adapters, each employee is passed to adapters serving its kind only
+func (a *aggregatedWebhookAdapter) EmployeeGVKs() []schema.GroupVersionKind {
+	var gvks []schema.GroupVersionKind
+	served := make(map[schema.GroupKind]bool)
+	for _, adapter := range a.adapters {
+		for _, gvk := range employeeGVKsOf(adapter) {
+			if !served[gvk.GroupKind()] {
+				served[gvk.GroupKind()] = true
+				gvks = append(gvks, gvk)
+			}
+		}
+	}
+	return gvks
+}
+
+// GetExpectedFinalizers uses store of the first adapter serving kind of employee
+func (a *aggregatedWebhookAdapter) GetExpectedFinalizers(employee client.Object) (map[string]string, error) {
+	return a.expectedFinalizerStoreOf(employee).GetExpectedFinalizers(employee)
+}
+
+func (a *aggregatedWebhookAdapter) SetExpectedFinalizers(employee client.Object, expectedFlzs map[string]string) error {
+	return a.expectedFinalizerStoreOf(employee).SetExpectedFinalizers(employee, expectedFlzs)
+}
+
+func (a *aggregatedWebhookAdapter) expectedFinalizerStoreOf(employee client.Object) ExpectedFinalizerStore {
+	groupKind := employeeGroupKind(employee)
+	for _, adapter := range a.adapters {
+		if servesEmployeeKind(adapter, groupKind) {
+			return expectedFinalizerStoreOf(adapter)
+		}
+	}
+	return annotationExpectedFinalizerStore{}
+}
+
+func (a *aggregatedWebhookAdapter) GetEmployersByEmployee(ctx context.Context, employee client.Object, c client.Client) ([]client.Object, error) {
+	employersByAdapter, err := a.getEmployersByAdapter(ctx, employee, c)
+	if err != nil {
+		return nil, err
+	}
+	return flattenEmployers(employersByAdapter, nil), nil
+}
+
+// getEmployersByAdapter returns employers keyed by index of adapters, adapters failed are absent under FailOpen policy
+// and those not serving kind of employee or observing only are absent always
+func (a *aggregatedWebhookAdapter) getEmployersByAdapter(ctx context.Context, employee client.Object, c client.Client) (map[int][]client.Object, error) {
+	employers := make([][]client.Object, len(a.adapters))
+	errs := make([]error, len(a.adapters))
+	groupKind := employeeGroupKind(employee)
+	var wg sync.WaitGroup
+	for i := range a.adapters {
+		if !servesEmployeeKind(a.adapters[i], groupKind) || observesOnly(a.adapters[i]) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// a panicking adapter fails like one returning error rather than crashing the webhook server
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			employers[i], errs[i] = a.adapters[i].GetEmployersByEmployee(ctx, employee, c)
+		}(i)
+	}
+	wg.Wait()
+
+	employersByAdapter := make(map[int][]client.Object, len(a.adapters))
+	var failed []error
+	for i := range a.adapters {
+		if !servesEmployeeKind(a.adapters[i], groupKind) || observesOnly(a.adapters[i]) {
+			continue
+		}
+		if errs[i] != nil {
+			failed = append(failed, fmt.Errorf("adapter %s failed, err: %w", a.adapters[i].Name(), errs[i]))
+			continue
+		}
+		employersByAdapter[i] = employers[i]
+	}
+	if len(failed) == 0 {
+		return employersByAdapter, nil
+	}
+	if a.policy == AggregationFailClosed {
+		return nil, errors2.NewAggregate(failed)
+	}
+	klog.Errorf("ignore adapters failed getting employers of %s/%s, err: %v",
+		employee.GetNamespace(), employee.GetName(), errors2.NewAggregate(failed))
+	return employersByAdapter, nil
+}
+
+// flattenEmployers merges employers of adapters, skipping those absent from others if others not nil
+func flattenEmployers(employersByAdapter, others map[int][]client.Object) []client.Object {
+	var merged []client.Object
+	for i, employers := range employersByAdapter {
+		if others != nil {
+			if _, ok := others[i]; !ok {
+				continue
+			}
+		}
+		merged = append(merged, employers...)
+	}
+	return merged
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/kube-api/apps/v1alpha1"
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+// fixedWebhookAdapter selects pods by a fixed employer, or fails if err set
+type fixedWebhookAdapter struct {
+	employer string
+	err      error
+}
+
+func (f *fixedWebhookAdapter) Name() string {
+	return "fixed-" + f.employer
+}
+
+func (f *fixedWebhookAdapter) GetEmployersByEmployee(_ context.Context, employee client.Object, _ client.Client) ([]client.Object, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: f.employer, Namespace: employee.GetNamespace()}}
+	svc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+	return []client.Object{svc}, nil
+}
+
+func TestAggregatedWebhookAdapter(t *testing.T) {
+	adapters := []WebhookAdapter{
+		&fixedWebhookAdapter{employer: "svc-a"},
+		&fixedWebhookAdapter{employer: "svc-b"},
+		&fixedWebhookAdapter{employer: "svc-c", err: errors.New("unavailable")},
+	}
+
+	closed := NewResourceConsistWebhook(nil, nil, NewAggregatedWebhookAdapter("aggregated", AggregationFailClosed, adapters...))
+	assert.Error(t, closed.Mutating(context.Background(), newLabelWebhookPod("", nil), admissionv1.Create))
+
+	open := NewResourceConsistWebhook(nil, nil, NewAggregatedWebhookAdapter("aggregated", AggregationFailOpen, adapters...))
+	pod := newLabelWebhookPod("", nil)
+	assert.NoError(t, open.Mutating(context.Background(), pod, admissionv1.Create))
+	var availableExpectedFlzs v1alpha1.PodAvailableConditions
+	assert.NoError(t, json.Unmarshal([]byte(pod.Annotations[v1alpha1.PodAvailableConditionsAnnotation]), &availableExpectedFlzs))
+	assert.Len(t, availableExpectedFlzs.ExpectedFinalizers, 2)
+	assert.Equal(t, utils.GenerateLifecycleFinalizer("svc-b"), availableExpectedFlzs.ExpectedFinalizers["Service/default/svc-b"])
+}
+
+// panickingWebhookAdapter is fixedWebhookAdapter panicking on getting employers
+type panickingWebhookAdapter struct {
+	fixedWebhookAdapter
+}
+
+func (p *panickingWebhookAdapter) GetEmployersByEmployee(_ context.Context, _ client.Object, _ client.Client) ([]client.Object, error) {
+	panic("adapter bug")
+}
+
+func TestAggregatedWebhookAdapterPanic(t *testing.T) {
+	adapters := []WebhookAdapter{
+		&fixedWebhookAdapter{employer: "svc-a"},
+		&panickingWebhookAdapter{fixedWebhookAdapter{employer: "svc-b"}},
+	}
+
+	closed := NewResourceConsistWebhook(nil, nil, NewAggregatedWebhookAdapter("aggregated", AggregationFailClosed, adapters...))
+	err := closed.Mutating(context.Background(), newLabelWebhookPod("", nil), admissionv1.Create)
+	assert.ErrorContains(t, err, "adapter bug")
+
+	open := NewResourceConsistWebhook(nil, nil, NewAggregatedWebhookAdapter("aggregated", AggregationFailOpen, adapters...))
+	pod := newLabelWebhookPod("", nil)
+	assert.NoError(t, open.Mutating(context.Background(), pod, admissionv1.Create))
+	var availableExpectedFlzs v1alpha1.PodAvailableConditions
+	assert.NoError(t, json.Unmarshal([]byte(pod.Annotations[v1alpha1.PodAvailableConditionsAnnotation]), &availableExpectedFlzs))
+	assert.Equal(t, map[string]string{"Service/default/svc-a": utils.GenerateLifecycleFinalizer("svc-a")},
+		availableExpectedFlzs.ExpectedFinalizers)
+}
+
+// observingWebhookAdapter is fixedWebhookAdapter whose controller runs in observe mode
+type observingWebhookAdapter struct {
+	fixedWebhookAdapter
+}
+
+func (o *observingWebhookAdapter) ObserveOnly() bool {
+	return true
+}
+
+func TestObserveOnlyAdapterNotInjected(t *testing.T) {
+	observing := &observingWebhookAdapter{fixedWebhookAdapter{employer: "svc-observed"}}
+	pod := newLabelWebhookPod("", nil)
+	assert.NoError(t, NewResourceConsistWebhook(nil, nil, observing).Mutating(context.Background(), pod, admissionv1.Create))
+	assert.NotContains(t, pod.Annotations, v1alpha1.PodAvailableConditionsAnnotation)
+
+	aggregated := NewAggregatedWebhookAdapter("aggregated", AggregationFailClosed, &fixedWebhookAdapter{employer: "svc-a"}, observing)
+	pod = newLabelWebhookPod("", nil)
+	assert.NoError(t, NewResourceConsistWebhook(nil, nil, aggregated).Mutating(context.Background(), pod, admissionv1.Create))
+	var availableExpectedFlzs v1alpha1.PodAvailableConditions
+	assert.NoError(t, json.Unmarshal([]byte(pod.Annotations[v1alpha1.PodAvailableConditionsAnnotation]), &availableExpectedFlzs))
+	assert.Len(t, availableExpectedFlzs.ExpectedFinalizers, 1)
+	assert.Contains(t, availableExpectedFlzs.ExpectedFinalizers, "Service/default/svc-a")
+}
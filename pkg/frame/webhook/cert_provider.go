@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/cert"
+)
+
+// certManagerInjectCAFromAnnotation asks the cert-manager CA injector to inject CA of the Certificate into webhooks
+const certManagerInjectCAFromAnnotation = "cert-manager.io/inject-ca-from"
+
+var (
+	_ CertProvider = &selfSignedCertProvider{}
+	_ CertProvider = &externalCertProvider{}
+	_ CertProvider = &certManagerCertProvider{}
+)
+
+// selfSignedCertProvider self-signs certs in secret resourceconsist-webhook-certs by default, rotates them before
+// expiry and injects CABundle into webhook configurations
+type selfSignedCertProvider struct {
+	clientset kubernetes.Interface
+	dnsName   string
+	certDir   string
+	options   CertRotationOptions
+}
+
+// NewSelfSignedCertProvider returns the CertProvider used by Initialize, see CertRotationOptions for rotation
+func NewSelfSignedCertProvider(config *rest.Config, dnsName, certDir string, options CertRotationOptions) (CertProvider, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &selfSignedCertProvider{
+		clientset: clientset,
+		dnsName:   dnsName,
+		certDir:   certDir,
+		options:   options.withDefaults(),
+	}, nil
+}
+
+func (p *selfSignedCertProvider) EnsureCerts(ctx context.Context) error {
+	return ensureWebhookCABundleAndCert(ctx, p.clientset, p.dnsName, p.certDir, p.options)
+}
+
+// externalCertProvider serves certs managed by others, CABundle of webhook configurations is left to them
+type externalCertProvider struct {
+	clientset  kubernetes.Interface
+	secretName string
+	certDir    string
+}
+
+// NewExternalCertProvider returns a CertProvider syncing tls.key and tls.crt of secret secretName in namespace of the
+// controller to certDir. If secretName is empty, certs in certDir are served as they are, only checked to be valid.
+// Webhook configurations are generated, while their CABundle should be injected by whoever manages the certs.
+func NewExternalCertProvider(config *rest.Config, secretName, certDir string) (CertProvider, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &externalCertProvider{
+		clientset:  clientset,
+		secretName: secretName,
+		certDir:    certDir,
+	}, nil
+}
+
+func (p *externalCertProvider) EnsureCerts(ctx context.Context) error {
+	if err := ensureWebhookConfigurations(ctx, p.clientset, nil, nil); err != nil {
+		return err
+	}
+	if p.secretName == "" {
+		_, err := tls.LoadX509KeyPair(filepath.Join(p.certDir, "tls.crt"), filepath.Join(p.certDir, "tls.key"))
+		if err != nil {
+			return fmt.Errorf("load certs in %s failed, err: %w", p.certDir, err)
+		}
+		return nil
+	}
+	tlsKey, tlsCert, err := getSecretCerts(ctx, p.clientset, p.secretName)
+	if err != nil {
+		return err
+	}
+	return ensureWebhookCert(p.certDir, tlsKey, tlsCert)
+}
+
+// certManagerCertProvider serves certs issued by cert-manager, whose CA injector injects CABundle into webhook
+// configurations
+type certManagerCertProvider struct {
+	clientset       kubernetes.Interface
+	certificateName string
+	secretName      string
+	certDir         string
+}
+
+// NewCertManagerCertProvider returns a CertProvider syncing certs in secret secretName, issued by cert-manager
+// Certificate certificateName in namespace of the controller, to certDir. Webhook configurations are generated with
+// cert-manager.io/inject-ca-from of the Certificate, and the serving cert is checked to be trusted by CABundle the
+// cert-manager CA injector injected. certificateName is secretName if empty.
+func NewCertManagerCertProvider(config *rest.Config, certificateName, secretName, certDir string) (CertProvider, error) {
+	if secretName == "" {
+		return nil, errors.New("secret name of cert-manager Certificate is required")
+	}
+	if certificateName == "" {
+		certificateName = secretName
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &certManagerCertProvider{
+		clientset:       clientset,
+		certificateName: certificateName,
+		secretName:      secretName,
+		certDir:         certDir,
+	}, nil
+}
+
+func (p *certManagerCertProvider) EnsureCerts(ctx context.Context) error {
+	err := ensureWebhookConfigurations(ctx, p.clientset, nil, map[string]string{
+		certManagerInjectCAFromAnnotation: getNamespace() + "/" + p.certificateName,
+	})
+	if err != nil {
+		return err
+	}
+	tlsKey, tlsCert, err := getSecretCerts(ctx, p.clientset, p.secretName)
+	if err != nil {
+		return err
+	}
+
+	mwhc, err := p.clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, getMutatingWebhookConfigurationName(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range mwhc.Webhooks {
+		if err := verifyServingCert(tlsCert, mwhc.Webhooks[i].ClientConfig.CABundle); err != nil {
+			return fmt.Errorf("cert in secret %s not trusted by CABundle of webhook %s, err: %w", p.secretName, mwhc.Webhooks[i].Name, err)
+		}
+	}
+	// certs written only after trusted, in case CABundle not injected yet after cert-manager renewing the CA
+	return ensureWebhookCert(p.certDir, tlsKey, tlsCert)
+}
+
+// getSecretCerts returns valid tls.key and tls.crt of secret secretName in namespace of the controller
+func getSecretCerts(ctx context.Context, clientset kubernetes.Interface, secretName string) ([]byte, []byte, error) {
+	secret, err := clientset.CoreV1().Secrets(getNamespace()).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsKey, tlsCert := secret.Data["tls.key"], secret.Data["tls.crt"]
+	if _, err := tls.X509KeyPair(tlsCert, tlsKey); err != nil {
+		return nil, nil, fmt.Errorf("invalid tls.crt and tls.key in secret %s, err: %w", secretName, err)
+	}
+	return tlsKey, tlsCert, nil
+}
+
+// verifyServingCert verifies the first cert in tlsCert PEM against caBundle, intermediates following it are allowed
+func verifyServingCert(tlsCert, caBundle []byte) error {
+	if len(caBundle) == 0 {
+		return errors.New("CABundle not injected yet")
+	}
+	caCerts, err := cert.ParseCertsPEM(caBundle)
+	if err != nil {
+		return err
+	}
+	servingCerts, err := cert.ParseCertsPEM(tlsCert)
+	if err != nil {
+		return err
+	}
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for _, caCert := range caCerts {
+		roots.AddCert(caCert)
+	}
+	for _, intermediate := range servingCerts[1:] {
+		intermediates.AddCert(intermediate)
+	}
+	_, err = servingCerts[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	return err
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+const (
+	defaultCertCheckInterval = time.Hour
+	defaultCertRenewBefore   = 30 * 24 * time.Hour
+	defaultCARenewBefore     = 90 * 24 * time.Hour
+	defaultCAOverlap         = time.Hour
+)
+
+// CertRotationOptions configures rotation of the self-signed CA and serving cert of webhook
+type CertRotationOptions struct {
+	// CheckInterval is the interval between two checks, 1 hour by default
+	CheckInterval time.Duration
+	// CertRenewBefore renews serving cert expiring within it, 30 days by default
+	CertRenewBefore time.Duration
+	// CARenewBefore renews CA expiring within it, 90 days by default. The old CA stays in CABundle until it expires.
+	CARenewBefore time.Duration
+	// CAOverlap is how long a new CA is trusted in CABundle before serving cert signed by it, 1 hour by default
+	CAOverlap time.Duration
+}
+
+func (o CertRotationOptions) withDefaults() CertRotationOptions {
+	if o.CheckInterval <= 0 {
+		o.CheckInterval = defaultCertCheckInterval
+	}
+	if o.CertRenewBefore <= 0 {
+		o.CertRenewBefore = defaultCertRenewBefore
+	}
+	if o.CARenewBefore <= 0 {
+		o.CARenewBefore = defaultCARenewBefore
+	}
+	if o.CAOverlap <= 0 {
+		o.CAOverlap = defaultCAOverlap
+	}
+	return o
+}
+
+var _ manager.Runnable = &certRefresher{}
+var _ manager.LeaderElectionRunnable = &certRefresher{}
+
+type certRefresher struct {
+	provider CertProvider
+	interval time.Duration
+}
+
+// AddCertProviderToMgr calls EnsureCerts of provider every interval, 1 hour by default. Certs rewritten in certDir are
+// reloaded by webhook server without restarting. It runs on every replica since each one serves with certs on its own
+// disk.
+func AddCertProviderToMgr(mgr manager.Manager, provider CertProvider, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultCertCheckInterval
+	}
+	return mgr.Add(&certRefresher{
+		provider: provider,
+		interval: interval,
+	})
+}
+
+// AddCertRotatorToMgr periodically renews self-signed webhook certs before expiry and re-injects CABundle into webhook
+// configurations, see AddCertProviderToMgr.
+func AddCertRotatorToMgr(mgr manager.Manager, config *rest.Config, dnsName, certDir string, options CertRotationOptions) error {
+	provider, err := NewSelfSignedCertProvider(config, dnsName, certDir, options)
+	if err != nil {
+		return err
+	}
+	return AddCertProviderToMgr(mgr, provider, options.CheckInterval)
+}
+
+func (c *certRefresher) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := c.provider.EnsureCerts(ctx); err != nil {
+			klog.Errorf("ensure webhook certs failed, err: %v", err)
+		}
+	}, c.interval)
+	return nil
+}
+
+func (c *certRefresher) NeedLeaderElection() bool {
+	return false
+}
+
+// rotateCertData returns renewed secret data of webhook certs and whether anything changed.
+//
+// A CA expiring within CARenewBefore is replaced, the old one is still trusted in ca.crt until it expires, so that
+// serving certs signed by either are accepted during the overlap. A serving cert is renewed if expiring within
+// CertRenewBefore or not signed by the current CA, after the current CA trusted for CAOverlap; an expired serving
+// cert or one not matching dnsName is renewed at once.
+func rotateCertData(data map[string][]byte, dnsName string, now time.Time, options CertRotationOptions) (map[string][]byte, bool, error) {
+	caCerts, err := cert.ParseCertsPEM(data["ca.crt"])
+	if err != nil {
+		return nil, false, fmt.Errorf("parse ca.crt failed, err: %w", err)
+	}
+	caKey, err := parseSigner(data["ca.key"])
+	if err != nil {
+		return nil, false, fmt.Errorf("parse ca.key failed, err: %w", err)
+	}
+	if _, err = tls.X509KeyPair(data["tls.crt"], data["tls.key"]); err != nil {
+		return nil, false, fmt.Errorf("parse tls.crt and tls.key failed, err: %w", err)
+	}
+	servingCerts, err := cert.ParseCertsPEM(data["tls.crt"])
+	if err != nil {
+		return nil, false, fmt.Errorf("parse tls.crt failed, err: %w", err)
+	}
+
+	rotated := false
+	caKeyPEM := data["ca.key"]
+	currentCA := caCerts[0]
+	if now.Add(options.CARenewBefore).After(currentCA.NotAfter) {
+		newCAKey, newCA, err := generateSelfSignedCACert()
+		if err != nil {
+			return nil, false, err
+		}
+		caKeyPEM, err = keyutil.MarshalPrivateKeyToPEM(newCAKey)
+		if err != nil {
+			return nil, false, err
+		}
+		caKey = newCAKey
+		currentCA = newCA
+		caCerts = append([]*x509.Certificate{newCA}, caCerts...)
+		rotated = true
+		klog.Infof("webhook CA expiring at %s renewed", caCerts[1].NotAfter)
+	}
+
+	trustedCAs := []*x509.Certificate{currentCA}
+	for _, caCert := range caCerts[1:] {
+		if now.Before(caCert.NotAfter) {
+			trustedCAs = append(trustedCAs, caCert)
+			continue
+		}
+		rotated = true
+	}
+
+	tlsKeyPEM, tlsCertPEM := data["tls.key"], data["tls.crt"]
+	servingCert := servingCerts[0]
+	invalid := !now.Before(servingCert.NotAfter) || servingCert.VerifyHostname(dnsName) != nil
+	expiring := now.Add(options.CertRenewBefore).After(servingCert.NotAfter)
+	signedByCurrentCA := servingCert.CheckSignatureFrom(currentCA) == nil
+	currentCATrusted := now.Sub(currentCA.NotBefore) >= options.CAOverlap
+	if invalid || ((expiring || !signedByCurrentCA) && currentCATrusted) {
+		privateKey, signedCert, err := generateSelfSignedCert(currentCA, caKey, dnsName)
+		if err != nil {
+			return nil, false, err
+		}
+		tlsKeyPEM, err = keyutil.MarshalPrivateKeyToPEM(privateKey)
+		if err != nil {
+			return nil, false, err
+		}
+		tlsCertPEM = utils.EncodeCertPEM(signedCert)
+		rotated = true
+		klog.Infof("webhook serving cert expiring at %s renewed", servingCert.NotAfter)
+	}
+
+	if !rotated {
+		return data, false, nil
+	}
+	var caBundle []byte
+	for _, caCert := range trustedCAs {
+		caBundle = append(caBundle, utils.EncodeCertPEM(caCert)...)
+	}
+	return map[string][]byte{
+		"ca.key": caKeyPEM, "ca.crt": caBundle,
+		"tls.key": tlsKeyPEM, "tls.crt": tlsCertPEM,
+	}, true, nil
+}
+
+func parseSigner(keyPEM []byte) (crypto.Signer, error) {
+	key, err := keyutil.ParsePrivateKeyPEM(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key is not a signer")
+	}
+	return signer, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+const testDNSName = "resourceconsist-manager.resourceconsist.svc"
+
+func newTestCertData(t *testing.T) map[string][]byte {
+	caKey, caCert, err := generateSelfSignedCACert()
+	assert.NoError(t, err)
+	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
+	assert.NoError(t, err)
+	privateKey, signedCert, err := generateSelfSignedCert(caCert, caKey, testDNSName)
+	assert.NoError(t, err)
+	privateKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(privateKey)
+	assert.NoError(t, err)
+	return map[string][]byte{
+		"ca.key": caKeyPEM, "ca.crt": utils.EncodeCertPEM(caCert),
+		"tls.key": privateKeyPEM, "tls.crt": utils.EncodeCertPEM(signedCert),
+	}
+}
+
+func TestRotateCertData(t *testing.T) {
+	options := CertRotationOptions{}.withDefaults()
+	data := newTestCertData(t)
+	now := time.Now()
+
+	_, rotated, err := rotateCertData(data, testDNSName, now, options)
+	assert.NoError(t, err)
+	assert.False(t, rotated, "fresh certs should not be rotated")
+
+	// serving cert expiring is renewed by the same CA
+	renewed, rotated, err := rotateCertData(data, testDNSName, now.Add(340*24*time.Hour), options)
+	assert.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Equal(t, data["ca.crt"], renewed["ca.crt"])
+	assert.NotEqual(t, data["tls.crt"], renewed["tls.crt"])
+
+	// CA expiring is renewed, the old one still trusted and serving cert kept until the new CA trusted for CAOverlap
+	caRenewOptions := options
+	caRenewOptions.CARenewBefore = 20 * 365 * 24 * time.Hour
+	renewed, rotated, err = rotateCertData(data, testDNSName, now, caRenewOptions)
+	assert.NoError(t, err)
+	assert.True(t, rotated)
+	caCerts, err := cert.ParseCertsPEM(renewed["ca.crt"])
+	assert.NoError(t, err)
+	assert.Len(t, caCerts, 2)
+	assert.Equal(t, data["tls.crt"], renewed["tls.crt"])
+
+	renewedAgain, rotated, err := rotateCertData(renewed, testDNSName, now.Add(2*options.CAOverlap), options)
+	assert.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Equal(t, renewed["ca.crt"], renewedAgain["ca.crt"])
+	servingCerts, err := cert.ParseCertsPEM(renewedAgain["tls.crt"])
+	assert.NoError(t, err)
+	assert.NoError(t, servingCerts[0].CheckSignatureFrom(caCerts[0]))
+
+	// serving cert not matching dns name is renewed at once
+	_, rotated, err = rotateCertData(data, "another.resourceconsist.svc", now, options)
+	assert.NoError(t, err)
+	assert.True(t, rotated)
+}
+
+func TestVerifyServingCert(t *testing.T) {
+	data, another := newTestCertData(t), newTestCertData(t)
+	assert.NoError(t, verifyServingCert(data["tls.crt"], data["ca.crt"]))
+	assert.NoError(t, verifyServingCert(data["tls.crt"], append(another["ca.crt"], data["ca.crt"]...)))
+	assert.Error(t, verifyServingCert(data["tls.crt"], another["ca.crt"]))
+	assert.Error(t, verifyServingCert(data["tls.crt"], nil))
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"kusionstack.io/kube-api/apps/v1alpha1"
+)
+
+var podGVK = corev1.SchemeGroupVersion.WithKind("Pod")
+
+// employeeGVKsOf returns kinds of employees served for adapter, Pod if not EmployeeKindsAdapter
+func employeeGVKsOf(adapter WebhookAdapter) []schema.GroupVersionKind {
+	if kinds, ok := adapter.(EmployeeKindsAdapter); ok {
+		if gvks := kinds.EmployeeGVKs(); len(gvks) > 0 {
+			return gvks
+		}
+	}
+	return []schema.GroupVersionKind{podGVK}
+}
+
+// servesEmployeeKind matches group and kind only, versions are converted by apiserver according to matchPolicy
+func servesEmployeeKind(adapter WebhookAdapter, groupKind schema.GroupKind) bool {
+	for _, gvk := range employeeGVKsOf(adapter) {
+		if gvk.GroupKind() == groupKind {
+			return true
+		}
+	}
+	return false
+}
+
+// newEmployee returns an empty employee to decode into, Pods are decoded as corev1.Pod as before and others as
+// unstructured objects
+func newEmployee(gvk schema.GroupVersionKind) client.Object {
+	if gvk.GroupKind() == podGVK.GroupKind() {
+		return &corev1.Pod{}
+	}
+	employee := &unstructured.Unstructured{}
+	employee.SetGroupVersionKind(gvk)
+	return employee
+}
+
+// employeeGroupKind returns group and kind of employee, typed Pods decoded without TypeMeta included
+func employeeGroupKind(employee client.Object) schema.GroupKind {
+	if gvk := employee.GetObjectKind().GroupVersionKind(); gvk.Kind != "" {
+		return gvk.GroupKind()
+	}
+	if _, ok := employee.(*corev1.Pod); ok {
+		return podGVK.GroupKind()
+	}
+	return schema.GroupKind{}
+}
+
+// employeeRules returns rules of kinds, resources and scopes resolved via mapper
+func employeeRules(mapper meta.RESTMapper, gvks []schema.GroupVersionKind, operations ...admissionregistrationv1.OperationType) ([]admissionregistrationv1.RuleWithOperations, error) {
+	rules := make([]admissionregistrationv1.RuleWithOperations, 0, len(gvks))
+	for _, gvk := range gvks {
+		if gvk.GroupKind() == podGVK.GroupKind() {
+			rules = append(rules, podRule(operations...))
+			continue
+		}
+		rule, err := ruleForKind(mapper, gvk, operations...)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func ruleForKind(mapper meta.RESTMapper, gvk schema.GroupVersionKind, operations ...admissionregistrationv1.OperationType) (admissionregistrationv1.RuleWithOperations, error) {
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return admissionregistrationv1.RuleWithOperations{}, err
+	}
+	scope := admissionregistrationv1.ClusterScope
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		scope = admissionregistrationv1.NamespacedScope
+	}
+	return newRule(gvk.Group, mapping.Resource.Resource, scope, operations...), nil
+}
+
+var _ ExpectedFinalizerStore = annotationExpectedFinalizerStore{}
+
+// annotationExpectedFinalizerStore keeps expected finalizers in annotation PodAvailableConditionsAnnotation, which
+// works for employees of any kind
+type annotationExpectedFinalizerStore struct{}
+
+func (annotationExpectedFinalizerStore) GetExpectedFinalizers(employee client.Object) (map[string]string, error) {
+	var availableExpectedFlzs v1alpha1.PodAvailableConditions
+	if anno := employee.GetAnnotations()[v1alpha1.PodAvailableConditionsAnnotation]; anno != "" {
+		if err := json.Unmarshal([]byte(anno), &availableExpectedFlzs); err != nil {
+			return nil, err
+		}
+	}
+	return availableExpectedFlzs.ExpectedFinalizers, nil
+}
+
+func (annotationExpectedFinalizerStore) SetExpectedFinalizers(employee client.Object, expectedFlzs map[string]string) error {
+	annoAvailableCondition, err := json.Marshal(v1alpha1.PodAvailableConditions{ExpectedFinalizers: expectedFlzs})
+	if err != nil {
+		return err
+	}
+	annotations := employee.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[v1alpha1.PodAvailableConditionsAnnotation] = string(annoAvailableCondition)
+	employee.SetAnnotations(annotations)
+	return nil
+}
+
+// expectedFinalizerStoreOf returns adapter if it is an ExpectedFinalizerStore, otherwise the annotation store
+func expectedFinalizerStoreOf(adapter WebhookAdapter) ExpectedFinalizerStore {
+	if store, ok := adapter.(ExpectedFinalizerStore); ok {
+		return store
+	}
+	return annotationExpectedFinalizerStore{}
+}
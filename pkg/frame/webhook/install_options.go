@@ -0,0 +1,131 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"os"
+	"sync"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+const (
+	defaultNamespace                     = "resourceconsist"
+	defaultServiceName                   = "resourceconsist-manager"
+	defaultCertsSecretName               = "resourceconsist-webhook-certs"
+	mutatingWebhookConfigurationSuffix   = "-mutating"
+	validatingWebhookConfigurationSuffix = "-validating"
+)
+
+// Option configures names of objects the webhook install owns, so that several installs in one cluster don't fight
+// over the same objects. Names not set are suffixed by install name of utils.SetInstallName.
+type Option func(*installOptions)
+
+type installOptions struct {
+	namespace                   string
+	serviceName                 string
+	certsSecretName             string
+	mutatingConfigurationName   string
+	validatingConfigurationName string
+}
+
+var (
+	installLock sync.RWMutex
+	install     installOptions
+)
+
+// WithNamespace sets namespace of webhook service and certs secret, POD_NAMESPACE or resourceconsist by default
+func WithNamespace(namespace string) Option {
+	return func(o *installOptions) {
+		o.namespace = namespace
+	}
+}
+
+// WithServiceName sets service of webhook, resourceconsist-manager by default
+func WithServiceName(name string) Option {
+	return func(o *installOptions) {
+		o.serviceName = name
+	}
+}
+
+// WithCertsSecretName sets secret of self-signed certs, resourceconsist-webhook-certs by default
+func WithCertsSecretName(name string) Option {
+	return func(o *installOptions) {
+		o.certsSecretName = name
+	}
+}
+
+// WithWebhookConfigurationNames sets webhook configurations, resourceconsist-manager-mutating and
+// resourceconsist-manager-validating by default
+func WithWebhookConfigurationNames(mutating, validating string) Option {
+	return func(o *installOptions) {
+		o.mutatingConfigurationName = mutating
+		o.validatingConfigurationName = validating
+	}
+}
+
+func applyOptions(opts []Option) {
+	installLock.Lock()
+	defer installLock.Unlock()
+	for _, opt := range opts {
+		opt(&install)
+	}
+}
+
+func getInstallOptions() installOptions {
+	installLock.RLock()
+	defer installLock.RUnlock()
+	return install
+}
+
+func getNamespace() string {
+	if ns := getInstallOptions().namespace; ns != "" {
+		return ns
+	}
+	if ns := os.Getenv("POD_NAMESPACE"); len(ns) > 0 {
+		return ns
+	}
+	return defaultNamespace
+}
+
+func getServiceName() string {
+	if name := getInstallOptions().serviceName; name != "" {
+		return name
+	}
+	return utils.InstallScopedName(defaultServiceName)
+}
+
+func getCertsSecretName() string {
+	if name := getInstallOptions().certsSecretName; name != "" {
+		return name
+	}
+	return utils.InstallScopedName(defaultCertsSecretName)
+}
+
+func getMutatingWebhookConfigurationName() string {
+	if name := getInstallOptions().mutatingConfigurationName; name != "" {
+		return name
+	}
+	return utils.InstallScopedName(defaultServiceName) + mutatingWebhookConfigurationSuffix
+}
+
+func getValidatingWebhookConfigurationName() string {
+	if name := getInstallOptions().validatingConfigurationName; name != "" {
+		return name
+	}
+	return utils.InstallScopedName(defaultServiceName) + validatingWebhookConfigurationSuffix
+}
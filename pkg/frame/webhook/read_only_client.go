@@ -0,0 +1,76 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"errors"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// errReadOnlyClient is returned by writes of adapters in webhook, which must be side-effect free since webhooks are
+// declared with sideEffects None and called for dry-run requests as well
+var errReadOnlyClient = errors.New("client passed to webhook adapters is read-only")
+
+var _ client.Client = &readOnlyClient{}
+
+type readOnlyClient struct {
+	client.Client
+}
+
+// newReadOnlyClient wraps cli rejecting all writes, nil is kept as it is
+func newReadOnlyClient(cli client.Client) client.Client {
+	if cli == nil {
+		return nil
+	}
+	return &readOnlyClient{Client: cli}
+}
+
+func (r *readOnlyClient) Create(context.Context, client.Object, ...client.CreateOption) error {
+	return errReadOnlyClient
+}
+
+func (r *readOnlyClient) Delete(context.Context, client.Object, ...client.DeleteOption) error {
+	return errReadOnlyClient
+}
+
+func (r *readOnlyClient) Update(context.Context, client.Object, ...client.UpdateOption) error {
+	return errReadOnlyClient
+}
+
+func (r *readOnlyClient) Patch(context.Context, client.Object, client.Patch, ...client.PatchOption) error {
+	return errReadOnlyClient
+}
+
+func (r *readOnlyClient) DeleteAllOf(context.Context, client.Object, ...client.DeleteAllOfOption) error {
+	return errReadOnlyClient
+}
+
+func (r *readOnlyClient) Status() client.StatusWriter {
+	return readOnlyStatusWriter{}
+}
+
+type readOnlyStatusWriter struct{}
+
+func (readOnlyStatusWriter) Update(context.Context, client.Object, ...client.UpdateOption) error {
+	return errReadOnlyClient
+}
+
+func (readOnlyStatusWriter) Patch(context.Context, client.Object, client.Patch, ...client.PatchOption) error {
+	return errReadOnlyClient
+}
@@ -17,21 +17,28 @@ limitations under the License.
 package webhook
 
 import (
+	"bytes"
 	"context"
 	"crypto"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/util/cert"
@@ -43,17 +50,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
-	"kusionstack.io/kube-api/apps/v1alpha1"
 	"kusionstack.io/resourceconsist/pkg/utils"
 )
 
-const (
-	mutatingWebhookConfigurationName   = "resourceconsist-manager-mutating"
-	validatingWebhookConfigurationName = "resourceconsist-manager-validating"
-	webhookCertsSecretName             = "resourceconsist-webhook-certs"
-)
-
-// AddToMgr is only necessary for controllers following PodOpsLifecycle
+// AddToMgr is only necessary for controllers following PodOpsLifecycle. Employees are Pods unless adapter implements
+// EmployeeKindsAdapter.
 func AddToMgr(mgr manager.Manager, adapter WebhookAdapter) error {
 	server := mgr.GetWebhookServer()
 	logger := mgr.GetLogger().WithName("webhook").V(3)
@@ -63,154 +64,242 @@ func AddToMgr(mgr manager.Manager, adapter WebhookAdapter) error {
 		return nil
 	}
 
+	rules, err := employeeRules(mgr.GetRESTMapper(), employeeGVKsOf(adapter), admissionregistrationv1.Create, admissionregistrationv1.Update)
+	if err != nil {
+		return err
+	}
 	path := adapter.Name()
 	if !strings.HasPrefix(path, "/") {
 		path = "/" + path
 	}
 	decoder, _ := admission.NewDecoder(mgr.GetScheme())
-	server.Register(path, &webhook.Admission{Handler: NewPodResourceConsistWebhook(mgr.GetClient(), decoder, adapter)})
+	server.Register(path, &webhook.Admission{Handler: NewResourceConsistWebhook(mgr.GetClient(), decoder, adapter)})
+	registerWebhook(newWebhookRegistration(adapter, path, rules), true)
 	logger.Info("Registered webhook handler", "path", path)
 
 	return nil
 }
 
-var _ admission.Handler = &PodResourceConsistWebhook{}
+var _ admission.Handler = &ResourceConsistWebhook{}
 
-func (r *PodResourceConsistWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
-	if req.DryRun != nil && *req.DryRun {
-		return admission.Allowed("dry run")
+// Handle patches dry-run requests the same as others, since computing the patch is side-effect free
+func (r *ResourceConsistWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	gvk := schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind}
+	if !servesEmployeeKind(r.WebhookAdapter, gvk.GroupKind()) {
+		return admission.Patched("NoMutating")
 	}
-	if req.Kind.Kind != "Pod" {
+
+	// nothing to decode for employees deleted
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
 		return admission.Patched("NoMutating")
 	}
 
-	pod := &corev1.Pod{}
-	err := r.Decode(req, pod)
+	employee := newEmployee(gvk)
+	err := r.Decode(req, employee)
 	if err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
-	err = r.Mutating(ctx, pod, req.Operation)
+	if req.Operation == admissionv1.Update {
+		oldEmployee := newEmployee(gvk)
+		err = r.DecodeRaw(req.OldObject, oldEmployee)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = r.MutatingUpdate(ctx, oldEmployee, employee)
+	} else {
+		err = r.Mutating(ctx, employee, req.Operation)
+	}
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
 
-	marshalled, err := json.Marshal(pod)
+	marshalled, err := json.Marshal(employee)
 	if err != nil {
 		return admission.Errored(http.StatusInternalServerError, err)
 	}
 	return admission.PatchResponseFromRaw(req.AdmissionRequest.Object.Raw, marshalled)
 }
 
-type PodResourceConsistWebhook struct {
+// ResourceConsistWebhook injects expected finalizers of employers into employees, see EmployeeKindsAdapter and
+// ExpectedFinalizerStore for employees other than Pods
+type ResourceConsistWebhook struct {
 	WebhookAdapter
 	client.Client
 	*admission.Decoder
 }
 
-func NewPodResourceConsistWebhook(cli client.Client, decoder *admission.Decoder, adapter WebhookAdapter) *PodResourceConsistWebhook {
-	return &PodResourceConsistWebhook{
+// PodResourceConsistWebhook is kept for compatibility.
+//
+// Deprecated: use ResourceConsistWebhook instead.
+type PodResourceConsistWebhook = ResourceConsistWebhook
+
+// NewResourceConsistWebhook passes a read-only client to adapter, see errReadOnlyClient
+func NewResourceConsistWebhook(cli client.Client, decoder *admission.Decoder, adapter WebhookAdapter) *ResourceConsistWebhook {
+	return &ResourceConsistWebhook{
 		adapter,
-		cli,
+		newReadOnlyClient(cli),
 		decoder,
 	}
 }
 
-func (r *PodResourceConsistWebhook) Mutating(ctx context.Context, newPod *corev1.Pod, operation admissionv1.Operation) error {
-	if newPod == nil {
+// NewPodResourceConsistWebhook is kept for compatibility.
+//
+// Deprecated: use NewResourceConsistWebhook instead.
+func NewPodResourceConsistWebhook(cli client.Client, decoder *admission.Decoder, adapter WebhookAdapter) *PodResourceConsistWebhook {
+	return NewResourceConsistWebhook(cli, decoder, adapter)
+}
+
+func (r *ResourceConsistWebhook) Mutating(ctx context.Context, newEmployee client.Object, operation admissionv1.Operation) error {
+	if newEmployee == nil {
 		return nil
 	}
 
-	// only concern pods new created
-	if operation != admissionv1.Create {
+	// only concern employees new created
+	if operation != admissionv1.Create || observesOnly(r.WebhookAdapter) {
 		return nil
 	}
 
-	employers, err := r.WebhookAdapter.GetEmployersByEmployee(ctx, newPod, r.Client)
+	employers, err := r.WebhookAdapter.GetEmployersByEmployee(ctx, newEmployee, r.Client)
 	if err != nil {
 		return err
 	}
 
-	availableExpectedFlzs := v1alpha1.PodAvailableConditions{
-		ExpectedFinalizers: map[string]string{},
+	// compatible if expected finalizers already exist during creation
+	store := expectedFinalizerStoreOf(r.WebhookAdapter)
+	expectedFlzs, err := store.GetExpectedFinalizers(newEmployee)
+	if err != nil {
+		return err
+	}
+	if expectedFlzs == nil {
+		expectedFlzs = map[string]string{}
+	}
+	for expectedFlzKey, expectedFlz := range expectedFinalizersOf(employers) {
+		expectedFlzs[expectedFlzKey] = expectedFlz
 	}
+	return store.SetExpectedFinalizers(newEmployee, expectedFlzs)
+}
 
-	// compatible if PodAvailableConditionsAnnotation already exist during creation
-	if newPod.GetAnnotations()[v1alpha1.PodAvailableConditionsAnnotation] != "" {
-		err = json.Unmarshal([]byte(newPod.GetAnnotations()[v1alpha1.PodAvailableConditionsAnnotation]), &availableExpectedFlzs)
-		if err != nil {
-			return err
-		}
+// MutatingUpdate adds expected finalizers of employers newEmployee joins and removes those of employers it leaves,
+// when labels of the employee updated. Expected finalizers of employers selecting both old and new employee are left
+// to controller.
+func (r *ResourceConsistWebhook) MutatingUpdate(ctx context.Context, oldEmployee, newEmployee client.Object) error {
+	if oldEmployee == nil || newEmployee == nil {
+		return nil
+	}
+	if labels.Equals(oldEmployee.GetLabels(), newEmployee.GetLabels()) || !newEmployee.GetDeletionTimestamp().IsZero() ||
+		observesOnly(r.WebhookAdapter) {
+		return nil
 	}
 
-	for _, employer := range employers {
-		expectedFlzKey := utils.GenerateLifecycleFinalizerKey(employer)
-		expectedFlz := utils.GenerateLifecycleFinalizer(employer.GetName())
-		availableExpectedFlzs.ExpectedFinalizers[expectedFlzKey] = expectedFlz
+	oldEmployers, newEmployers, err := r.getEmployersOfUpdate(ctx, oldEmployee, newEmployee)
+	if err != nil {
+		return err
 	}
-	annoAvailableCondition, err := json.Marshal(availableExpectedFlzs)
+	oldExpectedFlzs, newExpectedFlzs := expectedFinalizersOf(oldEmployers), expectedFinalizersOf(newEmployers)
+
+	store := expectedFinalizerStoreOf(r.WebhookAdapter)
+	expectedFlzs, err := store.GetExpectedFinalizers(newEmployee)
 	if err != nil {
 		return err
 	}
-	if newPod.Annotations == nil {
-		newPod.Annotations = make(map[string]string)
+	if expectedFlzs == nil {
+		expectedFlzs = map[string]string{}
 	}
-	newPod.Annotations[v1alpha1.PodAvailableConditionsAnnotation] = string(annoAvailableCondition)
 
-	return nil
+	changed := false
+	for expectedFlzKey := range oldExpectedFlzs {
+		if _, stillSelected := newExpectedFlzs[expectedFlzKey]; stillSelected {
+			continue
+		}
+		if _, exist := expectedFlzs[expectedFlzKey]; exist {
+			delete(expectedFlzs, expectedFlzKey)
+			changed = true
+		}
+	}
+	for expectedFlzKey, expectedFlz := range newExpectedFlzs {
+		if _, selectedBefore := oldExpectedFlzs[expectedFlzKey]; selectedBefore {
+			continue
+		}
+		if expectedFlzs[expectedFlzKey] != expectedFlz {
+			expectedFlzs[expectedFlzKey] = expectedFlz
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return store.SetExpectedFinalizers(newEmployee, expectedFlzs)
 }
 
-func Initialize(ctx context.Context, config *rest.Config, dnsName, certDir string) error {
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return err
+// getEmployersOfUpdate returns employers of oldEmployee and newEmployee. Aggregated adapters failed for either of them
+// are ignored for both, leaving their expected finalizers as they are.
+func (r *ResourceConsistWebhook) getEmployersOfUpdate(ctx context.Context, oldEmployee, newEmployee client.Object) ([]client.Object, []client.Object, error) {
+	aggregated, ok := r.WebhookAdapter.(*aggregatedWebhookAdapter)
+	if !ok {
+		oldEmployers, err := r.WebhookAdapter.GetEmployersByEmployee(ctx, oldEmployee, r.Client)
+		if err != nil {
+			return nil, nil, err
+		}
+		newEmployers, err := r.WebhookAdapter.GetEmployersByEmployee(ctx, newEmployee, r.Client)
+		return oldEmployers, newEmployers, err
 	}
-	return ensureWebhookCABundleAndCert(ctx, clientset, dnsName, certDir)
-}
 
-func ensureWebhookCABundleAndCert(ctx context.Context, clientset *kubernetes.Clientset, dnsName, certDir string) error {
-	secret, err := ensureWebhookSecret(ctx, clientset, dnsName)
+	oldEmployersByAdapter, err := aggregated.getEmployersByAdapter(ctx, oldEmployee, r.Client)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	klog.Infof("webhook secret ensured, secret: %s", secret.Name)
-
-	mwhc, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, mutatingWebhookConfigurationName, metav1.GetOptions{})
+	newEmployersByAdapter, err := aggregated.getEmployersByAdapter(ctx, newEmployee, r.Client)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	return flattenEmployers(oldEmployersByAdapter, newEmployersByAdapter), flattenEmployers(newEmployersByAdapter, oldEmployersByAdapter), nil
+}
 
-	for i := range mwhc.Webhooks {
-		if mwhc.Webhooks[i].ClientConfig.CABundle == nil {
-			mwhc.Webhooks[i].ClientConfig.CABundle = secret.Data["ca.crt"]
-		}
+// observesOnly returns true if adapter implements ObserveOnlyAdapter and is observing only
+func observesOnly(adapter WebhookAdapter) bool {
+	observeOnlyAdapter, ok := adapter.(ObserveOnlyAdapter)
+	return ok && observeOnlyAdapter.ObserveOnly()
+}
+
+// expectedFinalizersOf returns expected finalizers of employers, keyed by their expected finalizer keys
+func expectedFinalizersOf(employers []client.Object) map[string]string {
+	expectedFlzs := make(map[string]string, len(employers))
+	for _, employer := range employers {
+		expectedFlzs[utils.GenerateLifecycleFinalizerKey(employer)] = utils.GenerateLifecycleFinalizer(employer.GetName())
 	}
-	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		_, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Update(ctx, mwhc, metav1.UpdateOptions{})
-		return err
-	})
+	return expectedFlzs
+}
+
+// Initialize self-signs certs for webhook, see NewSelfSignedCertProvider and Option
+func Initialize(ctx context.Context, config *rest.Config, dnsName, certDir string, opts ...Option) error {
+	provider, err := NewSelfSignedCertProvider(config, dnsName, certDir, CertRotationOptions{})
 	if err != nil {
 		return err
 	}
+	return InitializeWithCertProvider(ctx, provider, opts...)
+}
 
-	vwhc, err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, validatingWebhookConfigurationName, metav1.GetOptions{})
+// InitializeWithCertProvider makes certs of webhook available via provider before webhook server started. opts apply
+// to the whole process, including certs refreshed later and handlers already registered.
+func InitializeWithCertProvider(ctx context.Context, provider CertProvider, opts ...Option) error {
+	applyOptions(opts)
+	return provider.EnsureCerts(ctx)
+}
+
+func ensureWebhookCABundleAndCert(ctx context.Context, clientset kubernetes.Interface, dnsName, certDir string, options CertRotationOptions) error {
+	secret, err := ensureWebhookSecret(ctx, clientset, dnsName, options)
 	if err != nil {
 		return err
 	}
+	klog.Infof("webhook secret ensured, secret: %s", secret.Name)
 
-	for i := range vwhc.Webhooks {
-		if vwhc.Webhooks[i].ClientConfig.CABundle == nil {
-			vwhc.Webhooks[i].ClientConfig.CABundle = secret.Data["ca.crt"]
-		}
-	}
-	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		_, err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(ctx, vwhc, metav1.UpdateOptions{})
-		return err
-	})
+	caBundle := secret.Data["ca.crt"]
+	err = ensureWebhookConfigurations(ctx, clientset, caBundle, nil)
 	if err != nil {
 		return err
 	}
-	klog.Infof("webhook ca bundle ensured, mutatingwebhookconfiguration: %s, validatingwebhookconfiguration: %s", mutatingWebhookConfigurationName, validatingWebhookConfigurationName)
+	klog.Infof("webhook ca bundle ensured, mutatingwebhookconfiguration: %s, validatingwebhookconfiguration: %s", getMutatingWebhookConfigurationName(), getValidatingWebhookConfigurationName())
 
 	var tlsKey, tlsCert []byte
 	tlsKey, ok := secret.Data["tls.key"]
@@ -231,72 +320,86 @@ func ensureWebhookCABundleAndCert(ctx context.Context, clientset *kubernetes.Cli
 	return nil
 }
 
-func ensureWebhookSecret(ctx context.Context, clientset *kubernetes.Clientset, dnsName string) (secret *corev1.Secret, err error) {
-	var (
-		found = true
-		dirty = false
-	)
-	secret, err = clientset.CoreV1().Secrets(getNamespace()).Get(ctx, webhookCertsSecretName, metav1.GetOptions{})
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			found = false
-		} else {
-			return
+// ensureWebhookSecret converges replicas initializing at the same time. The secret is only created, or replaced
+// with resourceVersion kept, so that the replica losing a race re-reads and adopts certs of the winner instead of
+// overwriting them.
+func ensureWebhookSecret(ctx context.Context, clientset kubernetes.Interface, dnsName string, options CertRotationOptions) (secret *corev1.Secret, err error) {
+	err = retryOnRace(func() error {
+		var errReconcile error
+		secret, errReconcile = reconcileWebhookSecret(ctx, clientset, dnsName, options)
+		return errReconcile
+	})
+	return
+}
+
+func reconcileWebhookSecret(ctx context.Context, clientset kubernetes.Interface, dnsName string, options CertRotationOptions) (*corev1.Secret, error) {
+	secret, err := clientset.CoreV1().Secrets(getNamespace()).Get(ctx, getCertsSecretName(), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	found := err == nil
+
+	if found && len(secret.Data) == 4 &&
+		secret.Data["ca.key"] != nil && secret.Data["ca.crt"] != nil &&
+		secret.Data["tls.key"] != nil && secret.Data["tls.crt"] != nil {
+		data, rotated, errRotate := rotateCertData(secret.Data, dnsName, time.Now(), options)
+		if errRotate == nil {
+			if !rotated {
+				return secret, nil
+			}
+			secret.Data = data
+			return clientset.CoreV1().Secrets(getNamespace()).Update(ctx, secret, metav1.UpdateOptions{})
 		}
+		klog.Errorf("webhook certs invalid and regenerated, err: %v", errRotate)
+	}
+
+	data, err := generateWebhookCertData(dnsName)
+	if err != nil {
+		return nil, err
 	}
 	if found {
-		if secret.Data == nil || len(secret.Data) != 4 ||
-			secret.Data["ca.key"] == nil || secret.Data["ca.crt"] == nil ||
-			secret.Data["tls.key"] == nil || secret.Data["tls.crt"] == nil {
-			dirty = true
-		}
-		if !dirty {
-			return
-		}
+		secret.Data = data
+		return clientset.CoreV1().Secrets(getNamespace()).Update(ctx, secret, metav1.UpdateOptions{})
 	}
+	return clientset.CoreV1().Secrets(getNamespace()).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getCertsSecretName(),
+			Namespace: getNamespace(),
+		},
+		Data: data,
+	}, metav1.CreateOptions{})
+}
 
+// retryOnRace retries fn on conflicts and creations raced by other replicas, fn should re-read objects it writes
+func retryOnRace(fn func() error) error {
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}, fn)
+}
+
+func generateWebhookCertData(dnsName string) (map[string][]byte, error) {
 	caKey, caCert, err := generateSelfSignedCACert()
 	if err != nil {
-		return
+		return nil, err
 	}
 	caKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(caKey)
 	if err != nil {
-		return
+		return nil, err
 	}
-	caCertPEM := utils.EncodeCertPEM(caCert)
 
 	privateKey, signedCert, err := generateSelfSignedCert(caCert, caKey, dnsName)
 	if err != nil {
-		return
+		return nil, err
 	}
 	privateKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(privateKey)
 	if err != nil {
-		return
+		return nil, err
 	}
-	signedCertPEM := utils.EncodeCertPEM(signedCert)
 
-	data := map[string][]byte{
-		"ca.key": caKeyPEM, "ca.crt": caCertPEM,
-		"tls.key": privateKeyPEM, "tls.crt": signedCertPEM,
-	}
-	secret = &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      webhookCertsSecretName,
-			Namespace: getNamespace(),
-		},
-		Data: data,
-	}
-
-	var updatedSecret *corev1.Secret
-	err = retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
-		if dirty {
-			updatedSecret, err = clientset.CoreV1().Secrets(getNamespace()).Update(ctx, secret, metav1.UpdateOptions{})
-		} else {
-			updatedSecret, err = clientset.CoreV1().Secrets(getNamespace()).Create(ctx, secret, metav1.CreateOptions{})
-		}
-		return err
-	})
-	return updatedSecret, err
+	return map[string][]byte{
+		"ca.key": caKeyPEM, "ca.crt": utils.EncodeCertPEM(caCert),
+		"tls.key": privateKeyPEM, "tls.crt": utils.EncodeCertPEM(signedCert),
+	}, nil
 }
 
 func generateSelfSignedCACert() (caKey *rsa.PrivateKey, caCert *x509.Certificate, err error) {
@@ -328,6 +431,8 @@ func generateSelfSignedCert(caCert *x509.Certificate, caKey crypto.Signer, dnsNa
 	return
 }
 
+// ensureWebhookCert writes certs atomically and verifies them on disk before served, so that webhook server never
+// loads a key and cert of different secrets written by replicas racing
 func ensureWebhookCert(certDir string, tlsKey, tlsCert []byte) error {
 	if _, err := os.Stat(certDir); os.IsNotExist(err) {
 		err := os.MkdirAll(certDir, 0777)
@@ -340,18 +445,55 @@ func ensureWebhookCert(certDir string, tlsKey, tlsCert []byte) error {
 	keyFile := filepath.Join(certDir, "tls.key")
 	certFile := filepath.Join(certDir, "tls.crt")
 
-	if err := os.WriteFile(keyFile, tlsKey, 0644); err != nil {
+	// certs rewritten are reloaded by webhook server, skip unchanged ones to avoid needless reloading
+	if verifyWebhookCert(keyFile, certFile, tlsKey, tlsCert) == nil {
+		return nil
+	}
+	if err := writeFileAtomically(keyFile, tlsKey); err != nil {
 		return err
 	}
-	if err := os.WriteFile(certFile, tlsCert, 0644); err != nil {
+	if err := writeFileAtomically(certFile, tlsCert); err != nil {
 		return err
 	}
+	return verifyWebhookCert(keyFile, certFile, tlsKey, tlsCert)
+}
+
+// verifyWebhookCert checks certs on disk are the expected ones and a valid key pair
+func verifyWebhookCert(keyFile, certFile string, tlsKey, tlsCert []byte) error {
+	existingKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	existingCert, err := os.ReadFile(certFile)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(existingKey, tlsKey) || !bytes.Equal(existingCert, tlsCert) {
+		return fmt.Errorf("certs in %s don't match those expected", filepath.Dir(certFile))
+	}
+	if _, err := tls.X509KeyPair(existingCert, existingKey); err != nil {
+		return fmt.Errorf("certs in %s invalid, err: %w", filepath.Dir(certFile), err)
+	}
 	return nil
 }
 
-func getNamespace() string {
-	if ns := os.Getenv("POD_NAMESPACE"); len(ns) > 0 {
-		return ns
+// writeFileAtomically renames a temp file written in the same directory to name
+func writeFileAtomically(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
 	}
-	return "resourceconsist"
+	return os.Rename(tmp.Name(), name)
 }
@@ -0,0 +1,200 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"kusionstack.io/kube-api/apps/v1alpha1"
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+// labelWebhookAdapter selects pods by the service named in label "svc"
+type labelWebhookAdapter struct{}
+
+func (l *labelWebhookAdapter) Name() string {
+	return "label"
+}
+
+func (l *labelWebhookAdapter) GetEmployersByEmployee(_ context.Context, employee client.Object, _ client.Client) ([]client.Object, error) {
+	name := employee.GetLabels()["svc"]
+	if name == "" {
+		return nil, nil
+	}
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: employee.GetNamespace()}}
+	svc.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Service"))
+	return []client.Object{svc}, nil
+}
+
+func newLabelWebhookPod(svc string, expectedFlzs map[string]string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Labels: map[string]string{"svc": svc}}}
+	if expectedFlzs != nil {
+		anno, _ := json.Marshal(v1alpha1.PodAvailableConditions{ExpectedFinalizers: expectedFlzs})
+		pod.Annotations = map[string]string{v1alpha1.PodAvailableConditionsAnnotation: string(anno)}
+	}
+	return pod
+}
+
+func TestMutatingUpdate(t *testing.T) {
+	r := NewResourceConsistWebhook(nil, nil, &labelWebhookAdapter{})
+	oldEmployers, _ := r.GetEmployersByEmployee(context.Background(), newLabelWebhookPod("svc-a", nil), nil)
+	newEmployers, _ := r.GetEmployersByEmployee(context.Background(), newLabelWebhookPod("svc-b", nil), nil)
+	oldKey, newKey := utils.GenerateLifecycleFinalizerKey(oldEmployers[0]), utils.GenerateLifecycleFinalizerKey(newEmployers[0])
+
+	oldPod := newLabelWebhookPod("svc-a", map[string]string{oldKey: "flz-a", "other": "flz-other"})
+	newPod := oldPod.DeepCopy()
+	newPod.Labels["svc"] = "svc-b"
+	assert.NoError(t, r.MutatingUpdate(context.Background(), oldPod, newPod))
+
+	var availableExpectedFlzs v1alpha1.PodAvailableConditions
+	assert.NoError(t, json.Unmarshal([]byte(newPod.Annotations[v1alpha1.PodAvailableConditionsAnnotation]), &availableExpectedFlzs))
+	assert.Equal(t, map[string]string{
+		newKey:  utils.GenerateLifecycleFinalizer("svc-b"),
+		"other": "flz-other",
+	}, availableExpectedFlzs.ExpectedFinalizers)
+
+	// labels unchanged
+	unchanged := oldPod.DeepCopy()
+	assert.NoError(t, r.MutatingUpdate(context.Background(), oldPod, unchanged))
+	assert.Equal(t, oldPod.Annotations, unchanged.Annotations)
+}
+
+func TestHandleDryRun(t *testing.T) {
+	decoder, _ := admission.NewDecoder(clientgoscheme.Scheme)
+	r := NewResourceConsistWebhook(nil, decoder, &labelWebhookAdapter{})
+	raw, _ := json.Marshal(newLabelWebhookPod("svc-a", nil))
+	dryRun := true
+	resp := r.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Operation: admissionv1.Create,
+		DryRun:    &dryRun,
+		Object:    runtime.RawExtension{Raw: raw},
+	}})
+	assert.True(t, resp.Allowed)
+	assert.Len(t, resp.Patches, 1)
+	assert.Equal(t, "/metadata/annotations", resp.Patches[0].Path)
+}
+
+func TestReadOnlyClient(t *testing.T) {
+	cli := newReadOnlyClient(&readOnlyClient{})
+	assert.ErrorIs(t, cli.Create(context.Background(), &corev1.Pod{}), errReadOnlyClient)
+	assert.ErrorIs(t, cli.Status().Update(context.Background(), &corev1.Pod{}), errReadOnlyClient)
+	assert.Nil(t, newReadOnlyClient(nil))
+}
+
+// crdWebhookAdapter selects employees of kind Foo, keeping expected finalizers in annotation "expected"
+type crdWebhookAdapter struct {
+	labelWebhookAdapter
+}
+
+var fooGVK = schema.GroupVersionKind{Group: "example.kusionstack.io", Version: "v1", Kind: "Foo"}
+
+func (c *crdWebhookAdapter) EmployeeGVKs() []schema.GroupVersionKind {
+	return []schema.GroupVersionKind{fooGVK}
+}
+
+func (c *crdWebhookAdapter) GetExpectedFinalizers(employee client.Object) (map[string]string, error) {
+	expectedFlzs := map[string]string{}
+	if anno := employee.GetAnnotations()["expected"]; anno != "" {
+		return expectedFlzs, json.Unmarshal([]byte(anno), &expectedFlzs)
+	}
+	return expectedFlzs, nil
+}
+
+func (c *crdWebhookAdapter) SetExpectedFinalizers(employee client.Object, expectedFlzs map[string]string) error {
+	anno, err := json.Marshal(expectedFlzs)
+	if err != nil {
+		return err
+	}
+	employee.SetAnnotations(map[string]string{"expected": string(anno)})
+	return nil
+}
+
+func TestHandleEmployeeKinds(t *testing.T) {
+	decoder, _ := admission.NewDecoder(clientgoscheme.Scheme)
+	r := NewResourceConsistWebhook(nil, decoder, &crdWebhookAdapter{})
+
+	podRaw, _ := json.Marshal(newLabelWebhookPod("svc-a", nil))
+	resp := r.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: podRaw},
+	}})
+	assert.True(t, resp.Allowed)
+	assert.Empty(t, resp.Patches)
+
+	foo := &unstructured.Unstructured{}
+	foo.SetGroupVersionKind(fooGVK)
+	foo.SetNamespace("default")
+	foo.SetName("foo")
+	foo.SetLabels(map[string]string{"svc": "svc-a"})
+	fooRaw, _ := foo.MarshalJSON()
+	resp = r.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: fooGVK.Group, Version: fooGVK.Version, Kind: fooGVK.Kind},
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: fooRaw},
+	}})
+	assert.True(t, resp.Allowed)
+	assert.Len(t, resp.Patches, 1)
+	assert.Equal(t, "/metadata/annotations", resp.Patches[0].Path)
+	assert.Contains(t, resp.Patches[0].Value, "expected")
+}
+
+func TestEnsureWebhookSecretRaced(t *testing.T) {
+	winnerData, err := generateWebhookCertData("webhook.svc")
+	assert.NoError(t, err)
+	clientset := fake.NewSimpleClientset()
+	// another replica creates the secret right before this one
+	raced := false
+	clientset.PrependReactor("create", "secrets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if raced {
+			return false, nil, nil
+		}
+		raced = true
+		winner := action.(clienttesting.CreateAction).GetObject().(*corev1.Secret).DeepCopy()
+		winner.Data = winnerData
+		assert.NoError(t, clientset.Tracker().Add(winner))
+		return true, nil, apierrors.NewAlreadyExists(corev1.Resource("secrets"), winner.Name)
+	})
+
+	secret, err := ensureWebhookSecret(context.Background(), clientset, "webhook.svc", CertRotationOptions{}.withDefaults())
+	assert.NoError(t, err)
+	assert.True(t, raced)
+	assert.Equal(t, winnerData, secret.Data)
+
+	certDir := t.TempDir()
+	assert.NoError(t, ensureWebhookCert(certDir, secret.Data["tls.key"], secret.Data["tls.crt"]))
+	assert.NoError(t, verifyWebhookCert(filepath.Join(certDir, "tls.key"), filepath.Join(certDir, "tls.crt"),
+		winnerData["tls.key"], winnerData["tls.crt"]))
+}
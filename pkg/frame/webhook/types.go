@@ -19,11 +19,59 @@ package webhook
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // WebhookAdapter should be implemented by adapters which follow PodOpsLifecycle
 type WebhookAdapter interface {
 	Name() string
+	// GetEmployersByEmployee must be side-effect free, it is called for dry-run requests as well, and writes via client
+	// are rejected
 	GetEmployersByEmployee(ctx context.Context, employee client.Object, client client.Client) ([]client.Object, error)
 }
+
+// CertProvider provides certs served by webhook
+type CertProvider interface {
+	// EnsureCerts makes serving certs available in certDir, and CABundle of webhook configurations trusted if the
+	// provider manages them. It is called once during Initialize and then periodically, see AddCertProviderToMgr.
+	EnsureCerts(ctx context.Context) error
+}
+
+// ValidatingWebhookAdapter should be implemented by adapters protecting their employers and employees, see
+// AddValidatingToMgr for the built-in checks
+type ValidatingWebhookAdapter interface {
+	WebhookAdapter
+	// NewEmployer returns an empty employer, deciding which kind of objects validated as employers
+	NewEmployer() client.Object
+	// GetEmployeeNamesByEmployer returns names of employees the employer selects according to its spec
+	GetEmployeeNamesByEmployer(ctx context.Context, employer client.Object, client client.Client) ([]string, error)
+}
+
+// AdmissionValidator is optional for ValidatingWebhookAdapter, validating updates of employers or employees beyond
+// the built-in checks. A non-nil error rejects the update.
+type AdmissionValidator interface {
+	ValidateUpdate(ctx context.Context, oldObj, newObj client.Object, client client.Client) error
+}
+
+// EmployeeKindsAdapter is optional for WebhookAdapter whose employees are not Pods. Employees of kinds other than Pod
+// are passed to GetEmployersByEmployee as unstructured objects.
+type EmployeeKindsAdapter interface {
+	// EmployeeGVKs returns kinds of employees expected finalizers injected into, Pod if empty
+	EmployeeGVKs() []schema.GroupVersionKind
+}
+
+// ObserveOnlyAdapter is optional for WebhookAdapter whose controller runs with controller.WithObserveOnly. Expected
+// finalizers are not injected for adapters observing only, since their controllers never remove them.
+type ObserveOnlyAdapter interface {
+	ObserveOnly() bool
+}
+
+// ExpectedFinalizerStore is optional for WebhookAdapter, deciding where expected finalizers of employees live.
+// Annotation PodAvailableConditionsAnnotation is used by default.
+type ExpectedFinalizerStore interface {
+	// GetExpectedFinalizers returns expected finalizers of employee keyed by expected finalizer keys, nil if none
+	GetExpectedFinalizers(employee client.Object) (map[string]string, error)
+	// SetExpectedFinalizers replaces expected finalizers of employee in place
+	SetExpectedFinalizers(employee client.Object, expectedFinalizers map[string]string) error
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+const (
+	defaultControllerServiceAccount = "resourceconsist-manager"
+	// DefaultMaxEmployeesDropRatio is MaxEmployeesDropRatio used if not set
+	DefaultMaxEmployeesDropRatio = 0.5
+)
+
+// ValidatingOptions configures the built-in checks of validating webhook
+type ValidatingOptions struct {
+	// ControllerUsernames are the only users allowed to remove LifecycleFinalizers from employees and CleanFinalizer
+	// from employers, service account resourceconsist-manager, suffixed by install name, in namespace of the webhook
+	// by default
+	ControllerUsernames []string
+	// MaxEmployeesDropRatio rejects updates of employer dropping more than this ratio of employees it selects, like a
+	// selector edited by mistake, DefaultMaxEmployeesDropRatio if 0, and the check is disabled if negative or not
+	// less than 1
+	MaxEmployeesDropRatio float64
+}
+
+// AddValidatingToMgr registers the validating handler of adapter at path "/validating-" + adapter.Name(). It rejects
+// updates by users other than ControllerUsernames removing LifecycleFinalizers of the adapter's employers from
+// employees, or removing CleanFinalizer from employers, and updates of employers dropping more than
+// MaxEmployeesDropRatio of their employees. Checks of AdmissionValidator follow if implemented.
+func AddValidatingToMgr(mgr manager.Manager, adapter ValidatingWebhookAdapter, options ValidatingOptions) error {
+	logger := mgr.GetLogger().WithName("webhook").V(3)
+	if len(adapter.Name()) == 0 {
+		logger.Info("Skip registering validating handlers without a name")
+		return nil
+	}
+
+	employerGVK, err := apiutil.GVKForObject(adapter.NewEmployer(), mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	employerRule, err := ruleForKind(mgr.GetRESTMapper(), employerGVK, admissionregistrationv1.Update)
+	if err != nil {
+		return err
+	}
+	rules, err := employeeRules(mgr.GetRESTMapper(), employeeGVKsOf(adapter), admissionregistrationv1.Update)
+	if err != nil {
+		return err
+	}
+
+	decoder, _ := admission.NewDecoder(mgr.GetScheme())
+	path := validatingPath(adapter.Name())
+	mgr.GetWebhookServer().Register(path, &webhook.Admission{Handler: &ResourceConsistValidatingWebhook{
+		ValidatingWebhookAdapter: adapter,
+		Client:                   newReadOnlyClient(mgr.GetClient()),
+		Decoder:                  decoder,
+		employerGVK:              employerGVK,
+		options:                  options,
+	}})
+	registerWebhook(newWebhookRegistration(adapter, path, append(rules, employerRule)), false)
+	logger.Info("Registered validating webhook handler", "path", path)
+	return nil
+}
+
+// controllerUsernames defaults to service account of the install, resolved at admission since namespace may be set
+// by Initialize after handlers registered
+func (r *ResourceConsistValidatingWebhook) controllerUsernames() []string {
+	if len(r.options.ControllerUsernames) != 0 {
+		return r.options.ControllerUsernames
+	}
+	return []string{"system:serviceaccount:" + getNamespace() + ":" + utils.InstallScopedName(defaultControllerServiceAccount)}
+}
+
+func (r *ResourceConsistValidatingWebhook) maxEmployeesDropRatio() float64 {
+	if r.options.MaxEmployeesDropRatio == 0 {
+		return DefaultMaxEmployeesDropRatio
+	}
+	return r.options.MaxEmployeesDropRatio
+}
+
+func validatingPath(name string) string {
+	return "/validating-" + strings.TrimPrefix(name, "/")
+}
+
+var _ admission.Handler = &ResourceConsistValidatingWebhook{}
+
+type ResourceConsistValidatingWebhook struct {
+	ValidatingWebhookAdapter
+	client.Client
+	*admission.Decoder
+
+	employerGVK schema.GroupVersionKind
+	options     ValidatingOptions
+}
+
+func (r *ResourceConsistValidatingWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	// finalizers removed and selectors edited only by updates
+	if req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	var oldObj, newObj client.Object
+	gvk := schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind}
+	isEmployee := false
+	switch {
+	case gvk.GroupKind() == r.employerGVK.GroupKind():
+		oldObj, newObj = r.NewEmployer(), r.NewEmployer()
+	case servesEmployeeKind(r.ValidatingWebhookAdapter, gvk.GroupKind()):
+		oldObj, newObj = newEmployee(gvk), newEmployee(gvk)
+		isEmployee = true
+	default:
+		return admission.Allowed("")
+	}
+	if err := r.DecodeRaw(req.OldObject, oldObj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if err := r.DecodeRaw(req.Object, newObj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	var err error
+	fromController := sets.NewString(r.controllerUsernames()...).Has(req.UserInfo.Username)
+	if isEmployee {
+		err = r.validateEmployeeUpdate(ctx, oldObj, newObj, fromController)
+	} else {
+		err = r.validateEmployerUpdate(ctx, oldObj, newObj, fromController)
+	}
+	if err == nil {
+		if validator, ok := r.ValidatingWebhookAdapter.(AdmissionValidator); ok {
+			err = validator.ValidateUpdate(ctx, oldObj, newObj, r.Client)
+		}
+	}
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
+
+// validateEmployeeUpdate rejects LifecycleFinalizers of employers selecting the employee removed by others
+func (r *ResourceConsistValidatingWebhook) validateEmployeeUpdate(ctx context.Context, oldEmployee, newEmployee client.Object, fromController bool) error {
+	removed := removedFinalizers(oldEmployee, newEmployee)
+	if fromController || len(removed) == 0 {
+		return nil
+	}
+	employers, err := r.GetEmployersByEmployee(ctx, oldEmployee, r.Client)
+	if err != nil {
+		return err
+	}
+	for _, employer := range employers {
+		lifecycleFlz := utils.GenerateLifecycleFinalizer(employer.GetName())
+		if removed.Has(lifecycleFlz) {
+			return fmt.Errorf("lifecycle finalizer %s of %s/%s can only be removed by resourceconsist controller",
+				lifecycleFlz, employer.GetNamespace(), employer.GetName())
+		}
+	}
+	return nil
+}
+
+// validateEmployerUpdate rejects CleanFinalizer removed by others, and too many employees dropped
+func (r *ResourceConsistValidatingWebhook) validateEmployerUpdate(ctx context.Context, oldEmployer, newEmployer client.Object, fromController bool) error {
+	if fromController {
+		return nil
+	}
+	for _, flz := range removedFinalizers(oldEmployer, newEmployer).List() {
+		if flz == utils.GenerateCleanFinalizer() || flz == utils.GenerateOldCleanFinalizer(newEmployer.GetName()) {
+			return fmt.Errorf("clean finalizer %s can only be removed by resourceconsist controller", flz)
+		}
+	}
+
+	ratio := r.maxEmployeesDropRatio()
+	if ratio <= 0 || ratio >= 1 || !newEmployer.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+	oldEmployees, err := r.GetEmployeeNamesByEmployer(ctx, oldEmployer, r.Client)
+	if err != nil {
+		return err
+	}
+	if len(oldEmployees) == 0 {
+		return nil
+	}
+	newEmployees, err := r.GetEmployeeNamesByEmployer(ctx, newEmployer, r.Client)
+	if err != nil {
+		return err
+	}
+	dropped := sets.NewString(oldEmployees...).Difference(sets.NewString(newEmployees...)).Len()
+	if float64(dropped) > ratio*float64(len(oldEmployees)) {
+		return fmt.Errorf("update drops %d of %d employees, more than ratio %v allowed", dropped, len(oldEmployees), ratio)
+	}
+	return nil
+}
+
+func removedFinalizers(oldObj, newObj client.Object) sets.String {
+	return sets.NewString(oldObj.GetFinalizers()...).Difference(sets.NewString(newObj.GetFinalizers()...))
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"kusionstack.io/resourceconsist/pkg/utils"
+)
+
+const testControllerUsername = "system:serviceaccount:resourceconsist:resourceconsist-manager"
+
+// labelValidatingAdapter selects pods named in annotation "pods" of services
+type labelValidatingAdapter struct {
+	labelWebhookAdapter
+}
+
+func (l *labelValidatingAdapter) NewEmployer() client.Object {
+	return &corev1.Service{}
+}
+
+func (l *labelValidatingAdapter) GetEmployeeNamesByEmployer(_ context.Context, employer client.Object, _ client.Client) ([]string, error) {
+	var names []string
+	_ = json.Unmarshal([]byte(employer.GetAnnotations()["pods"]), &names)
+	return names, nil
+}
+
+func newTestValidatingWebhook() *ResourceConsistValidatingWebhook {
+	decoder, _ := admission.NewDecoder(clientgoscheme.Scheme)
+	return &ResourceConsistValidatingWebhook{
+		ValidatingWebhookAdapter: &labelValidatingAdapter{},
+		Decoder:                  decoder,
+		employerGVK:              corev1.SchemeGroupVersion.WithKind("Service"),
+		options: ValidatingOptions{
+			ControllerUsernames:   []string{testControllerUsername},
+			MaxEmployeesDropRatio: 0.5,
+		},
+	}
+}
+
+func newTestUpdateRequest(kind, username string, oldObj, newObj runtime.Object) admission.Request {
+	oldRaw, _ := json.Marshal(oldObj)
+	newRaw, _ := json.Marshal(newObj)
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: kind},
+		Operation: admissionv1.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: username},
+		OldObject: runtime.RawExtension{Raw: oldRaw},
+		Object:    runtime.RawExtension{Raw: newRaw},
+	}}
+}
+
+func TestValidatingLifecycleFinalizerRemoved(t *testing.T) {
+	r := newTestValidatingWebhook()
+	oldPod := newLabelWebhookPod("svc-a", nil)
+	oldPod.Finalizers = []string{utils.GenerateLifecycleFinalizer("svc-a"), "others"}
+	newPod := oldPod.DeepCopy()
+	newPod.Finalizers = []string{"others"}
+
+	assert.False(t, r.Handle(context.Background(), newTestUpdateRequest("Pod", "someone", oldPod, newPod)).Allowed)
+	assert.True(t, r.Handle(context.Background(), newTestUpdateRequest("Pod", testControllerUsername, oldPod, newPod)).Allowed)
+
+	// finalizers of others are not protected
+	newPod.Finalizers = []string{utils.GenerateLifecycleFinalizer("svc-a")}
+	assert.True(t, r.Handle(context.Background(), newTestUpdateRequest("Pod", "someone", oldPod, newPod)).Allowed)
+}
+
+func TestValidatingEmployer(t *testing.T) {
+	r := newTestValidatingWebhook()
+	oldSvc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "default",
+		Finalizers:  []string{utils.GenerateCleanFinalizer()},
+		Annotations: map[string]string{"pods": `["pod-1","pod-2","pod-3","pod-4"]`}}}
+
+	newSvc := oldSvc.DeepCopy()
+	newSvc.Finalizers = nil
+	assert.False(t, r.Handle(context.Background(), newTestUpdateRequest("Service", "someone", oldSvc, newSvc)).Allowed)
+
+	newSvc = oldSvc.DeepCopy()
+	newSvc.Annotations["pods"] = `["pod-1","pod-2"]`
+	assert.True(t, r.Handle(context.Background(), newTestUpdateRequest("Service", "someone", oldSvc, newSvc)).Allowed)
+	newSvc.Annotations["pods"] = `["pod-1"]`
+	assert.False(t, r.Handle(context.Background(), newTestUpdateRequest("Service", "someone", oldSvc, newSvc)).Allowed)
+	assert.True(t, r.Handle(context.Background(), newTestUpdateRequest("Service", testControllerUsername, oldSvc, newSvc)).Allowed)
+}
+
+func TestValidatingMaxEmployeesDropRatioDefault(t *testing.T) {
+	r := newTestValidatingWebhook()
+	oldSvc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "default",
+		Annotations: map[string]string{"pods": `["pod-1","pod-2","pod-3","pod-4"]`}}}
+	newSvc := oldSvc.DeepCopy()
+	newSvc.Annotations["pods"] = `["pod-1"]`
+
+	r.options.MaxEmployeesDropRatio = 0
+	assert.False(t, r.Handle(context.Background(), newTestUpdateRequest("Service", "someone", oldSvc, newSvc)).Allowed)
+	r.options.MaxEmployeesDropRatio = -1
+	assert.True(t, r.Handle(context.Background(), newTestUpdateRequest("Service", "someone", oldSvc, newSvc)).Allowed)
+}
@@ -0,0 +1,360 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/pointer"
+
+	"kusionstack.io/kube-api/apps/v1alpha1"
+)
+
+const (
+	webhookNameSuffix      = ".resourceconsist.kusionstack.io"
+	defaultWebhookTimeout  = 10
+	defaultWebhookSvcPort  = 443
+	webhookRuleAllVersions = "*"
+)
+
+// WebhookConfigurationOptions is optional for WebhookAdapter and ValidatingWebhookAdapter, customizing webhooks
+// generated for the adapter
+type WebhookConfigurationOptions interface {
+	// ObjectSelector selects objects sent to webhook, objects labeled kusionstack.io/control=true by default
+	ObjectSelector() *metav1.LabelSelector
+	// FailurePolicy is Fail by default
+	FailurePolicy() admissionregistrationv1.FailurePolicyType
+}
+
+// webhookRegistration is a handler registered via AddToMgr or AddValidatingToMgr, generated as a webhook
+type webhookRegistration struct {
+	name           string
+	path           string
+	rules          []admissionregistrationv1.RuleWithOperations
+	objectSelector *metav1.LabelSelector
+	failurePolicy  admissionregistrationv1.FailurePolicyType
+}
+
+var (
+	registrationLock        sync.Mutex
+	mutatingRegistrations   []webhookRegistration
+	validatingRegistrations []webhookRegistration
+)
+
+func newWebhookRegistration(adapter WebhookAdapter, path string, rules []admissionregistrationv1.RuleWithOperations) webhookRegistration {
+	registration := webhookRegistration{
+		name:  strings.ReplaceAll(strings.Trim(path, "/"), "/", "-") + webhookNameSuffix,
+		path:  path,
+		rules: rules,
+		objectSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{v1alpha1.ControlledByKusionStackLabelKey: "true"},
+		},
+		failurePolicy: admissionregistrationv1.Fail,
+	}
+	if options, ok := adapter.(WebhookConfigurationOptions); ok {
+		if selector := options.ObjectSelector(); selector != nil {
+			registration.objectSelector = selector
+		}
+		if policy := options.FailurePolicy(); policy != "" {
+			registration.failurePolicy = policy
+		}
+	}
+	return registration
+}
+
+func registerWebhook(registration webhookRegistration, mutating bool) {
+	registrationLock.Lock()
+	defer registrationLock.Unlock()
+	if mutating {
+		mutatingRegistrations = append(mutatingRegistrations, registration)
+	} else {
+		validatingRegistrations = append(validatingRegistrations, registration)
+	}
+}
+
+// registeredWebhooks returns registrations sorted by name, so that webhooks generated don't depend on the order
+// adapters added
+func registeredWebhooks() ([]webhookRegistration, []webhookRegistration) {
+	registrationLock.Lock()
+	defer registrationLock.Unlock()
+	return sortedRegistrations(mutatingRegistrations), sortedRegistrations(validatingRegistrations)
+}
+
+func sortedRegistrations(registrations []webhookRegistration) []webhookRegistration {
+	sorted := append([]webhookRegistration(nil), registrations...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].name < sorted[j].name
+	})
+	return sorted
+}
+
+// isGeneratedWebhook returns whether the webhook named is generated from registrations rather than added by others
+func isGeneratedWebhook(name string) bool {
+	return strings.HasSuffix(name, webhookNameSuffix)
+}
+
+func podRule(operations ...admissionregistrationv1.OperationType) admissionregistrationv1.RuleWithOperations {
+	return newRule("", "pods", admissionregistrationv1.NamespacedScope, operations...)
+}
+
+func newRule(group, resource string, scope admissionregistrationv1.ScopeType, operations ...admissionregistrationv1.OperationType) admissionregistrationv1.RuleWithOperations {
+	return admissionregistrationv1.RuleWithOperations{
+		Operations: operations,
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{group},
+			APIVersions: []string{webhookRuleAllVersions},
+			Resources:   []string{resource},
+			Scope:       &scope,
+		},
+	}
+}
+
+// webhookClientConfig sets fields defaulted by apiserver as well, so that desired webhooks compare equal to those got
+func webhookClientConfig(path string, caBundle []byte) admissionregistrationv1.WebhookClientConfig {
+	return admissionregistrationv1.WebhookClientConfig{
+		Service: &admissionregistrationv1.ServiceReference{
+			Namespace: getNamespace(),
+			Name:      getServiceName(),
+			Path:      pointer.String(path),
+			Port:      pointer.Int32(defaultWebhookSvcPort),
+		},
+		CABundle: caBundle,
+	}
+}
+
+func desiredMutatingWebhooks(registrations []webhookRegistration, caBundle []byte) []admissionregistrationv1.MutatingWebhook {
+	webhooks := make([]admissionregistrationv1.MutatingWebhook, len(registrations))
+	for i, registration := range registrations {
+		sideEffects := admissionregistrationv1.SideEffectClassNone
+		matchPolicy := admissionregistrationv1.Equivalent
+		reinvocationPolicy := admissionregistrationv1.NeverReinvocationPolicy
+		failurePolicy := registration.failurePolicy
+		webhooks[i] = admissionregistrationv1.MutatingWebhook{
+			Name:                    registration.name,
+			ClientConfig:            webhookClientConfig(registration.path, caBundle),
+			Rules:                   registration.rules,
+			FailurePolicy:           &failurePolicy,
+			MatchPolicy:             &matchPolicy,
+			NamespaceSelector:       &metav1.LabelSelector{},
+			ObjectSelector:          registration.objectSelector,
+			SideEffects:             &sideEffects,
+			TimeoutSeconds:          pointer.Int32(defaultWebhookTimeout),
+			AdmissionReviewVersions: []string{"v1", "v1beta1"},
+			ReinvocationPolicy:      &reinvocationPolicy,
+		}
+	}
+	return webhooks
+}
+
+func desiredValidatingWebhooks(registrations []webhookRegistration, caBundle []byte) []admissionregistrationv1.ValidatingWebhook {
+	webhooks := make([]admissionregistrationv1.ValidatingWebhook, len(registrations))
+	for i, registration := range registrations {
+		sideEffects := admissionregistrationv1.SideEffectClassNone
+		matchPolicy := admissionregistrationv1.Equivalent
+		failurePolicy := registration.failurePolicy
+		webhooks[i] = admissionregistrationv1.ValidatingWebhook{
+			Name:                    registration.name,
+			ClientConfig:            webhookClientConfig(registration.path, caBundle),
+			Rules:                   registration.rules,
+			FailurePolicy:           &failurePolicy,
+			MatchPolicy:             &matchPolicy,
+			NamespaceSelector:       &metav1.LabelSelector{},
+			ObjectSelector:          registration.objectSelector,
+			SideEffects:             &sideEffects,
+			TimeoutSeconds:          pointer.Int32(defaultWebhookTimeout),
+			AdmissionReviewVersions: []string{"v1", "v1beta1"},
+		}
+	}
+	return webhooks
+}
+
+// mergeMutatingWebhooks keeps webhooks added by others in place, replaces generated ones with desired and appends
+// desired ones not existing yet
+func mergeMutatingWebhooks(existing, desired []admissionregistrationv1.MutatingWebhook) []admissionregistrationv1.MutatingWebhook {
+	desiredIndex := make(map[string]int, len(desired))
+	for i, webhook := range desired {
+		desiredIndex[webhook.Name] = i
+	}
+	merged := make([]admissionregistrationv1.MutatingWebhook, 0, len(existing)+len(desired))
+	for _, webhook := range existing {
+		if i, ok := desiredIndex[webhook.Name]; ok {
+			merged = append(merged, desired[i])
+			delete(desiredIndex, webhook.Name)
+		} else if !isGeneratedWebhook(webhook.Name) {
+			merged = append(merged, webhook)
+		}
+	}
+	for _, webhook := range desired {
+		if _, ok := desiredIndex[webhook.Name]; ok {
+			merged = append(merged, webhook)
+		}
+	}
+	return merged
+}
+
+// mergeValidatingWebhooks is mergeMutatingWebhooks for validating webhooks
+func mergeValidatingWebhooks(existing, desired []admissionregistrationv1.ValidatingWebhook) []admissionregistrationv1.ValidatingWebhook {
+	desiredIndex := make(map[string]int, len(desired))
+	for i, webhook := range desired {
+		desiredIndex[webhook.Name] = i
+	}
+	merged := make([]admissionregistrationv1.ValidatingWebhook, 0, len(existing)+len(desired))
+	for _, webhook := range existing {
+		if i, ok := desiredIndex[webhook.Name]; ok {
+			merged = append(merged, desired[i])
+			delete(desiredIndex, webhook.Name)
+		} else if !isGeneratedWebhook(webhook.Name) {
+			merged = append(merged, webhook)
+		}
+	}
+	for _, webhook := range desired {
+		if _, ok := desiredIndex[webhook.Name]; ok {
+			merged = append(merged, webhook)
+		}
+	}
+	return merged
+}
+
+// inheritMutatingCABundles keeps CABundle injected by others into generated webhooks, webhooks newly generated take
+// that of other generated ones
+func inheritMutatingCABundles(existing, desired []admissionregistrationv1.MutatingWebhook) {
+	caBundles := map[string][]byte{}
+	var shared []byte
+	for _, webhook := range existing {
+		if isGeneratedWebhook(webhook.Name) && len(webhook.ClientConfig.CABundle) != 0 {
+			caBundles[webhook.Name] = webhook.ClientConfig.CABundle
+			shared = webhook.ClientConfig.CABundle
+		}
+	}
+	for i := range desired {
+		if caBundle, ok := caBundles[desired[i].Name]; ok {
+			desired[i].ClientConfig.CABundle = caBundle
+		} else {
+			desired[i].ClientConfig.CABundle = shared
+		}
+	}
+}
+
+// inheritValidatingCABundles is inheritMutatingCABundles for validating webhooks
+func inheritValidatingCABundles(existing, desired []admissionregistrationv1.ValidatingWebhook) {
+	caBundles := map[string][]byte{}
+	var shared []byte
+	for _, webhook := range existing {
+		if isGeneratedWebhook(webhook.Name) && len(webhook.ClientConfig.CABundle) != 0 {
+			caBundles[webhook.Name] = webhook.ClientConfig.CABundle
+			shared = webhook.ClientConfig.CABundle
+		}
+	}
+	for i := range desired {
+		if caBundle, ok := caBundles[desired[i].Name]; ok {
+			desired[i].ClientConfig.CABundle = caBundle
+		} else {
+			desired[i].ClientConfig.CABundle = shared
+		}
+	}
+}
+
+// mergeAnnotations sets annotations to meta, returns whether any changed
+func mergeAnnotations(meta *metav1.ObjectMeta, annotations map[string]string) bool {
+	changed := false
+	for k, v := range annotations {
+		if meta.Annotations[k] == v {
+			continue
+		}
+		if meta.Annotations == nil {
+			meta.Annotations = map[string]string{}
+		}
+		meta.Annotations[k] = v
+		changed = true
+	}
+	return changed
+}
+
+// ensureWebhookConfigurations creates or updates webhook configurations to match handlers registered, webhooks added
+// to the configurations by others are kept. CABundle of generated webhooks is set to caBundle if not nil, otherwise
+// it is owned by others, e.g. cert-manager injecting CA according to annotations, and kept as injected.
+// Nothing to ensure without any handler registered via AddToMgr or AddValidatingToMgr.
+func ensureWebhookConfigurations(ctx context.Context, clientset kubernetes.Interface, caBundle []byte, annotations map[string]string) error {
+	mutatingRegistered, validatingRegistered := registeredWebhooks()
+	if len(mutatingRegistered) == 0 && len(validatingRegistered) == 0 {
+		return nil
+	}
+
+	// configurations created by other replicas at the same time are re-read
+	err := retryOnRace(func() error {
+		mutatingWebhooks := desiredMutatingWebhooks(mutatingRegistered, caBundle)
+		mwhc, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, getMutatingWebhookConfigurationName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Create(ctx, &admissionregistrationv1.MutatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: getMutatingWebhookConfigurationName(), Annotations: annotations},
+				Webhooks:   mutatingWebhooks,
+			}, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if caBundle == nil {
+			inheritMutatingCABundles(mwhc.Webhooks, mutatingWebhooks)
+		}
+		merged := mergeMutatingWebhooks(mwhc.Webhooks, mutatingWebhooks)
+		annotationsChanged := mergeAnnotations(&mwhc.ObjectMeta, annotations)
+		if !annotationsChanged && apiequality.Semantic.DeepEqual(mwhc.Webhooks, merged) {
+			return nil
+		}
+		mwhc.Webhooks = merged
+		_, err = clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Update(ctx, mwhc, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return retryOnRace(func() error {
+		validatingWebhooks := desiredValidatingWebhooks(validatingRegistered, caBundle)
+		vwhc, err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, getValidatingWebhookConfigurationName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().Create(ctx, &admissionregistrationv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: getValidatingWebhookConfigurationName(), Annotations: annotations},
+				Webhooks:   validatingWebhooks,
+			}, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if caBundle == nil {
+			inheritValidatingCABundles(vwhc.Webhooks, validatingWebhooks)
+		}
+		merged := mergeValidatingWebhooks(vwhc.Webhooks, validatingWebhooks)
+		annotationsChanged := mergeAnnotations(&vwhc.ObjectMeta, annotations)
+		if !annotationsChanged && apiequality.Semantic.DeepEqual(vwhc.Webhooks, merged) {
+			return nil
+		}
+		vwhc.Webhooks = merged
+		_, err = clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().Update(ctx, vwhc, metav1.UpdateOptions{})
+		return err
+	})
+}
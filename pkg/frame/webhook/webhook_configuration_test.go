@@ -0,0 +1,141 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type ignoredWebhookAdapter struct {
+	labelWebhookAdapter
+}
+
+func (i *ignoredWebhookAdapter) ObjectSelector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: map[string]string{"app": "demo"}}
+}
+
+func (i *ignoredWebhookAdapter) FailurePolicy() admissionregistrationv1.FailurePolicyType {
+	return admissionregistrationv1.Ignore
+}
+
+func TestDesiredMutatingWebhooks(t *testing.T) {
+	rules := []admissionregistrationv1.RuleWithOperations{podRule(admissionregistrationv1.Create)}
+	webhooks := desiredMutatingWebhooks([]webhookRegistration{
+		newWebhookRegistration(&labelWebhookAdapter{}, "/label", rules),
+		newWebhookRegistration(&ignoredWebhookAdapter{}, "/ignored", rules),
+	}, []byte("ca"))
+
+	assert.Len(t, webhooks, 2)
+	assert.Equal(t, "label.resourceconsist.kusionstack.io", webhooks[0].Name)
+	assert.Equal(t, "/label", *webhooks[0].ClientConfig.Service.Path)
+	assert.Equal(t, []byte("ca"), webhooks[0].ClientConfig.CABundle)
+	assert.Equal(t, admissionregistrationv1.Fail, *webhooks[0].FailurePolicy)
+	assert.Equal(t, "true", webhooks[0].ObjectSelector.MatchLabels["kusionstack.io/control"])
+
+	assert.Equal(t, admissionregistrationv1.Ignore, *webhooks[1].FailurePolicy)
+	assert.Equal(t, "demo", webhooks[1].ObjectSelector.MatchLabels["app"])
+}
+
+func TestEnsureWebhookConfigurationsMerged(t *testing.T) {
+	registrationLock.Lock()
+	saved := mutatingRegistrations
+	mutatingRegistrations = nil
+	registrationLock.Unlock()
+	defer func() {
+		registrationLock.Lock()
+		mutatingRegistrations = saved
+		registrationLock.Unlock()
+	}()
+
+	rules := []admissionregistrationv1.RuleWithOperations{podRule(admissionregistrationv1.Create)}
+	registerWebhook(newWebhookRegistration(&labelWebhookAdapter{}, "/zeta", rules), true)
+	registerWebhook(newWebhookRegistration(&labelWebhookAdapter{}, "/alpha", rules), true)
+	mutating, _ := registeredWebhooks()
+	assert.Equal(t, "alpha.resourceconsist.kusionstack.io", mutating[0].name)
+	assert.Equal(t, "zeta.resourceconsist.kusionstack.io", mutating[1].name)
+
+	// webhooks added by others kept, stale generated ones dropped
+	clientset := fake.NewSimpleClientset(&admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: getMutatingWebhookConfigurationName()},
+		Webhooks: []admissionregistrationv1.MutatingWebhook{
+			{Name: "other.example.com"},
+			{Name: "zeta.resourceconsist.kusionstack.io"},
+			{Name: "stale.resourceconsist.kusionstack.io"},
+		},
+	})
+	ctx := context.Background()
+	require.NoError(t, ensureWebhookConfigurations(ctx, clientset, []byte("ca"), nil))
+	mwhc, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx,
+		getMutatingWebhookConfigurationName(), metav1.GetOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, webhook := range mwhc.Webhooks {
+		names = append(names, webhook.Name)
+	}
+	assert.Equal(t, []string{"other.example.com", "zeta.resourceconsist.kusionstack.io",
+		"alpha.resourceconsist.kusionstack.io"}, names)
+	assert.Equal(t, []byte("ca"), mwhc.Webhooks[1].ClientConfig.CABundle)
+}
+
+func TestEnsureWebhookConfigurationsCAOwnedByOthers(t *testing.T) {
+	registrationLock.Lock()
+	saved := mutatingRegistrations
+	mutatingRegistrations = nil
+	registrationLock.Unlock()
+	defer func() {
+		registrationLock.Lock()
+		mutatingRegistrations = saved
+		registrationLock.Unlock()
+	}()
+
+	rules := []admissionregistrationv1.RuleWithOperations{podRule(admissionregistrationv1.Create)}
+	registerWebhook(newWebhookRegistration(&labelWebhookAdapter{}, "/alpha", rules), true)
+	registerWebhook(newWebhookRegistration(&labelWebhookAdapter{}, "/beta", rules), true)
+	annotations := map[string]string{certManagerInjectCAFromAnnotation: "resourceconsist/webhook-cert"}
+	clientset := fake.NewSimpleClientset(&admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: getMutatingWebhookConfigurationName()},
+		Webhooks: []admissionregistrationv1.MutatingWebhook{{
+			Name:         "alpha.resourceconsist.kusionstack.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{CABundle: []byte("injected")},
+		}},
+	})
+	ctx := context.Background()
+	require.NoError(t, ensureWebhookConfigurations(ctx, clientset, nil, annotations))
+
+	mwhc, err := clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx,
+		getMutatingWebhookConfigurationName(), metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "resourceconsist/webhook-cert", mwhc.Annotations[certManagerInjectCAFromAnnotation])
+	require.Len(t, mwhc.Webhooks, 2)
+	// injected CA kept, and taken by webhook newly generated
+	assert.Equal(t, []byte("injected"), mwhc.Webhooks[0].ClientConfig.CABundle)
+	assert.Equal(t, []byte("injected"), mwhc.Webhooks[1].ClientConfig.CABundle)
+	assert.Equal(t, "/beta", *mwhc.Webhooks[1].ClientConfig.Service.Path)
+
+	// created with annotations if not existing
+	vwhc, err := clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx,
+		getValidatingWebhookConfigurationName(), metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, annotations, vwhc.Annotations)
+}
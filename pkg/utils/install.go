@@ -0,0 +1,105 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	finalizerDomain       = "resource-consist.kusionstack.io"
+	defaultCleanFinalizer = finalizerDomain + "/clean-finalizer"
+	// oldCleanFinalizerPrefix is deprecated, only removed by the default install
+	oldCleanFinalizerPrefix = finalizerDomain + "/clean-"
+
+	lifecycleFinalizerKeySeparator = ":"
+)
+
+// installName is empty for the default install
+var installName string
+
+// SetInstallName distinguishes this install from others of resourceconsist in one cluster. Finalizers and expected
+// finalizer keys are scoped to it, so that installs leave those of each other alone. It must be called before any
+// controller or webhook added to manager. Name must be a DNS-1123 label, empty for the default install.
+func SetInstallName(name string) error {
+	if name != "" {
+		if errs := validation.IsDNS1123Label(name); len(errs) != 0 {
+			return fmt.Errorf("invalid install name %q: %s", name, strings.Join(errs, ", "))
+		}
+	}
+	installName = name
+	return nil
+}
+
+func GetInstallName() string {
+	return installName
+}
+
+// InstallScopedName suffixes name with install name, name is returned as it is for the default install
+func InstallScopedName(name string) string {
+	if installName == "" {
+		return name
+	}
+	return name + "-" + installName
+}
+
+// InstallScopedKey prefixes annotation key or condition type with install name, the prefix of key like
+// "resource-consist.kusionstack.io/deletion-policy" becomes "<install>.resource-consist.kusionstack.io", and key without
+// prefix becomes "<install>/<key>". Key is returned as it is for the default install.
+func InstallScopedKey(key string) string {
+	if installName == "" {
+		return key
+	}
+	if strings.Contains(key, "/") {
+		return installName + "." + key
+	}
+	return installName + "/" + key
+}
+
+// GenerateCleanFinalizer returns the clean finalizer added to employers, prefixed by install name for non-default
+// installs
+func GenerateCleanFinalizer() string {
+	if installName == "" {
+		return defaultCleanFinalizer
+	}
+	return installName + "." + defaultCleanFinalizer
+}
+
+// GenerateOldCleanFinalizer returns the deprecated clean finalizer of employer, empty for non-default installs which
+// never added it
+func GenerateOldCleanFinalizer(employerName string) string {
+	if installName != "" {
+		return ""
+	}
+	return oldCleanFinalizerPrefix + employerName
+}
+
+// TrimLifecycleFinalizerKeyInstall returns key generated by GenerateLifecycleFinalizerKey without install name, false if
+// key belongs to other installs
+func TrimLifecycleFinalizerKeyInstall(key string) (string, bool) {
+	if installName == "" {
+		return key, !strings.Contains(key, lifecycleFinalizerKeySeparator)
+	}
+	prefix := installName + lifecycleFinalizerKeySeparator
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(key, prefix), true
+}
@@ -38,12 +38,21 @@ import (
 	"kusionstack.io/kube-api/apps/v1alpha1"
 )
 
+// GenerateLifecycleFinalizerKey prefixes key with install name for non-default installs, see SetInstallName
 func GenerateLifecycleFinalizerKey(employer client.Object) string {
-	return fmt.Sprintf("%s/%s/%s", employer.GetObjectKind().GroupVersionKind().Kind,
+	key := fmt.Sprintf("%s/%s/%s", employer.GetObjectKind().GroupVersionKind().Kind,
 		employer.GetNamespace(), employer.GetName())
+	if installName == "" {
+		return key
+	}
+	return installName + lifecycleFinalizerKeySeparator + key
 }
 
+// GenerateLifecycleFinalizer hashes install name as well for non-default installs, see SetInstallName
 func GenerateLifecycleFinalizer(employerName string) string {
+	if installName != "" {
+		employerName = installName + "/" + employerName
+	}
 	b := md5.Sum([]byte(employerName))
 	return v1alpha1.PodOperationProtectionFinalizerPrefix + "/" + hex.EncodeToString(b[:])[8:24]
 }